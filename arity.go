@@ -0,0 +1,108 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+// Nodes of a tree with arity k are laid out level by level with the root at index 1, so the children of the node at index i are
+// at indices k*(i-1)+2 to k*i+1.  For binary trees this is the familiar 2i and 2i+1.
+
+// checkArity checks that the arity is supported.  An arity of 0 is treated as binary.
+func checkArity(arity int) error {
+	switch arity {
+	case 0, 2, 4, 8, 16:
+		return nil
+	default:
+		return errors.New("arity must be 2, 4, 8 or 16")
+	}
+}
+
+// arityOf returns the arity as a uint64, treating 0 as binary.
+func arityOf(arity int) uint64 {
+	if arity == 0 {
+		return 2
+	}
+
+	return uint64(arity)
+}
+
+// firstChild returns the index of the first child of the node at the given index.
+func firstChild(index uint64, arity uint64) uint64 {
+	return arity*(index-1) + 2
+}
+
+// parentOf returns the index of the parent of the node at the given index.
+func parentOf(index uint64, arity uint64) uint64 {
+	return (index-2)/arity + 1
+}
+
+// leafWidth returns the number of leaves of a tree whose first leaf is at the given index.
+func leafWidth(leafOffset uint64, arity uint64) uint64 {
+	return (leafOffset-1)*(arity-1) + 1
+}
+
+// levelStart returns the index of the first node at the given level, where the root is at level 0.
+func levelStart(level int, arity uint64) uint64 {
+	start := uint64(1)
+	width := uint64(1)
+	for i := 0; i < level; i++ {
+		start += width
+		width *= arity
+	}
+
+	return start
+}
+
+// depthFor returns the number of levels of branches required for a tree holding the given number of values.
+func depthFor(values uint64, arity uint64) int {
+	depth := 0
+	for width := uint64(1); width < values; width *= arity {
+		depth++
+	}
+
+	return depth
+}
+
+// pollardWidth returns the number of nodes in the highest level of a pollard of the given length.
+func pollardWidth(length int, arity uint64) int {
+	above := 0
+	width := 1
+	for above+width < length {
+		above += width
+		width *= int(arity)
+	}
+
+	return length - above
+}
+
+// sortHashes sorts the hashes in place by value.
+func sortHashes(hashes [][]byte) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i], hashes[j]) == -1
+	})
+}
+
+// arity returns the arity of the tree.
+func (t *MerkleTree) arity() uint64 {
+	return arityOf(t.Arity)
+}
+
+// leafOffset returns the index of the first leaf in the nodes of the tree.
+func (t *MerkleTree) leafOffset() uint64 {
+	return parentOf(uint64(len(t.Nodes)), t.arity())
+}
@@ -0,0 +1,252 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/poseidon"
+)
+
+func TestArityLayout(t *testing.T) {
+	tests := []struct {
+		arity  uint64
+		index  uint64
+		first  uint64
+		parent uint64
+	}{
+		{arity: 2, index: 1, first: 2},
+		{arity: 2, index: 5, first: 10, parent: 2},
+		{arity: 4, index: 1, first: 2},
+		{arity: 4, index: 2, first: 6, parent: 1},
+		{arity: 4, index: 5, first: 18, parent: 1},
+		{arity: 4, index: 6, first: 22, parent: 2},
+		{arity: 16, index: 17, first: 258, parent: 1},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.first, firstChild(test.index, test.arity), fmt.Sprintf("failed at test %d", i))
+		if test.index > 1 {
+			assert.Equal(t, test.parent, parentOf(test.index, test.arity), fmt.Sprintf("failed at test %d", i))
+		}
+	}
+
+	assert.Equal(t, uint64(8), levelStart(3, 2))
+	assert.Equal(t, uint64(22), levelStart(3, 4))
+	assert.Equal(t, 0, depthFor(1, 4))
+	assert.Equal(t, 1, depthFor(4, 4))
+	assert.Equal(t, 2, depthFor(5, 4))
+}
+
+func TestArityRoot(t *testing.T) {
+	hashType := keccak256.New()
+	data := make([][]byte, 5)
+	leaves := make([][]byte, 16)
+	for i := range leaves {
+		if i < len(data) {
+			data[i] = []byte(fmt.Sprintf("value %d", i))
+			leaves[i] = hashType.Hash(data[i])
+		} else {
+			leaves[i] = make([]byte, 32)
+		}
+	}
+	root := hashType.Hash(
+		hashType.Hash(leaves[0:4]...),
+		hashType.Hash(leaves[4:8]...),
+		hashType.Hash(leaves[8:12]...),
+		hashType.Hash(leaves[12:16]...),
+	)
+
+	tree, err := NewTree(WithData(data), WithHashType(hashType), WithArity(4))
+	require.NoError(t, err)
+	assert.Equal(t, root, tree.Root())
+	assert.Len(t, tree.Nodes, 22)
+	assert.Len(t, tree.Pollard(1), 5)
+
+	proof, err := tree.GenerateProofWithIndex(4, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, proof.Arity)
+	assert.Equal(t, [][]byte{leaves[5], leaves[6], leaves[7], tree.Nodes[2], tree.Nodes[4], tree.Nodes[5]}, proof.Hashes)
+}
+
+func TestArityProofs(t *testing.T) {
+	for _, hashType := range []HashType{keccak256.New(), blake2b.New(), nonStreamingHashType{keccak256.New()}} {
+		for _, arity := range []int{4, 8, 16} {
+			for _, sorted := range []bool{false, true} {
+				for _, values := range []int{1, 2, 3, 4, 5, 9, 17, 33} {
+					name := fmt.Sprintf("%s/arity=%d/sorted=%t/values=%d", hashType.HashName(), arity, sorted, values)
+					data := make([][]byte, values)
+					for i := range data {
+						data[i] = []byte(fmt.Sprintf("value %d", i))
+					}
+					tree, err := NewTree(
+						WithData(data),
+						WithHashType(hashType),
+						WithArity(arity),
+						WithSorted(sorted),
+						// Salts are generated from the original index, so cannot be verified for sorted trees.
+						WithSalt(!sorted),
+					)
+					require.NoError(t, err, name)
+
+					for i := range tree.Data {
+						proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+						require.NoError(t, err, name)
+						assert.Len(t, proof.Hashes, depthFor(uint64(values), uint64(arity))*(arity-1), name)
+						disclosure := &Disclosure{Value: tree.Data[i], Salt: tree.leafSalt(uint64(i)), Proof: proof}
+						verified, err := disclosure.Verify(tree.Root(), LayerOf(tree))
+						require.NoError(t, err, name)
+						assert.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+
+						if !sorted && values > arity {
+							proof, err = tree.GenerateProofWithIndex(uint64(i), 1)
+							require.NoError(t, err, name)
+							verified, err = VerifyProofUsing(tree.Data[i], !sorted, proof, tree.Pollard(1), hashType)
+							require.NoError(t, err, name)
+							assert.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+							assert.True(t, VerifyPollardWithArity(tree.Pollard(1), arity, hashType), name)
+						}
+					}
+
+					for i := range tree.Data {
+						for j := i; j < len(tree.Data); j++ {
+							multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{uint64(i), uint64(j)})
+							require.NoError(t, err, name)
+							verified, err := multiProof.Verify([][]byte{tree.Data[i], tree.Data[j]}, tree.Root())
+							require.NoError(t, err, name)
+							assert.True(t, verified, fmt.Sprintf("%s/indices=%d,%d", name, i, j))
+						}
+					}
+
+					assert.NotPanics(t, func() { tree.DOT(nil, nil) }, name)
+				}
+			}
+		}
+	}
+}
+
+func TestArityFieldElements(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}, {0x04}, {0x05}}
+	tree, err := NewTree(
+		WithData(data),
+		WithHashType(poseidon.NewFieldElements()),
+		WithArity(4),
+	)
+	require.NoError(t, err)
+
+	proof, err := tree.GenerateProof(data[4], 0)
+	require.NoError(t, err)
+	verified, err := VerifyProofUsing(data[4], false, proof, [][]byte{tree.Root()}, tree.Hash)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	_, err = proof.CircuitWitness(data[4], false, 8, tree.Hash)
+	assert.EqualError(t, err, "proofs of trees with an arity other than 2 are not supported")
+}
+
+func TestArityBadProofs(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux"), []byte("Quux")}
+	tree, err := NewTree(WithData(data), WithArity(4))
+	require.NoError(t, err)
+	proof, err := tree.GenerateProofWithIndex(1, 0)
+	require.NoError(t, err)
+
+	// Wrong arity.
+	badProof := *proof
+	badProof.Arity = 2
+	verified, err := VerifyProof(data[1], false, &badProof, [][]byte{tree.Root()})
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Unsupported arity.
+	badProof.Arity = 3
+	verified, err = VerifyProof(data[1], false, &badProof, [][]byte{tree.Root()})
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Incomplete level.
+	badProof = *proof
+	badProof.Hashes = badProof.Hashes[:len(badProof.Hashes)-1]
+	verified, err = VerifyProof(data[1], false, &badProof, [][]byte{tree.Root()})
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Bad pollards.
+	pollard := tree.Pollard(1)
+	assert.False(t, VerifyPollardWithArity(pollard[:4], 4, tree.Hash))
+	assert.False(t, VerifyPollardWithArity(pollard, 3, tree.Hash))
+	badPollard := append([][]byte{}, pollard...)
+	badPollard[2] = badPollard[3]
+	assert.False(t, VerifyPollardWithArity(badPollard, 4, tree.Hash))
+}
+
+func TestArityDOT(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux"), []byte("Quux")}
+	tree, err := NewTree(WithData(data), WithArity(4))
+	require.NoError(t, err)
+
+	dot := tree.DOT(new(StringFormatter), nil)
+	assert.Contains(t, dot, "6->2;")
+	assert.Contains(t, dot, "10->3;")
+	assert.Contains(t, dot, "21->5;")
+	assert.Contains(t, dot, "5->1;")
+
+	proof, err := tree.GenerateProofWithIndex(4, 0)
+	require.NoError(t, err)
+	dot = tree.DOTProof(proof, new(StringFormatter), new(HexFormatter))
+	for _, index := range []int{11, 12, 13, 2, 4, 5} {
+		assert.Contains(t, dot, fmt.Sprintf("%d [label=\"%x\" style=filled fillcolor=\"#00ff00\"];", index, tree.Nodes[index]))
+	}
+	assert.Contains(t, dot, fmt.Sprintf("1 [label=\"%x\" style=filled fillcolor=\"#8080ff\"];", tree.Nodes[1]))
+}
+
+func TestArityEncoding(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+		WithArity(8),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+	assert.Contains(t, string(exported), `"arity":8`)
+
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	assert.Equal(t, tree.Root(), newTree.Root())
+	proof, err := newTree.GenerateProofWithIndex(2, 0)
+	require.NoError(t, err)
+	verified, err := VerifyProof([]byte("Baz"), false, proof, [][]byte{tree.Root()})
+	require.NoError(t, err)
+	assert.True(t, verified)
+}
+
+func TestArityErrors(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+
+	_, err := NewTree(WithData(data), WithArity(3))
+	assert.EqualError(t, err, "problem with parameters: arity must be 2, 4, 8 or 16")
+
+	_, err = NewTree(WithData(data), WithArity(4), WithPadding(PaddingNone))
+	assert.EqualError(t, err, "problem with parameters: unbalanced trees must have an arity of 2")
+
+	_, err = NewMultiProof(WithValues(4), WithIndices([]uint64{0}), WithArity(32))
+	assert.EqualError(t, err, "problem with parameters: arity must be 2, 4, 8 or 16")
+}
@@ -15,31 +15,102 @@
 package blake2b
 
 import (
+	"errors"
+	"fmt"
+	"hash"
+	"runtime"
+	"sync"
+
 	"golang.org/x/crypto/blake2b"
 )
 
-const _hashlength = 32
+const (
+	_hashlength    = 32
+	_maxhashlength = blake2b.Size
+	_maxkeylength  = blake2b.Size
+	// _minparallelpairs is the minimum number of pairs per worker for HashPairs() to hash in parallel.
+	_minparallelpairs = 1024
+)
 
 // BLAKE2b is the Blake2b hashing method.
-type BLAKE2b struct{}
+type BLAKE2b struct {
+	// length is the length of the hash output, in bytes.  0 is the default of 32 bytes.
+	length int
+	// key is the key for keyed (MAC) mode.  nil if unkeyed.
+	key []byte
+}
 
 // New creates a new Blake2b hashing method.
 func New() *BLAKE2b {
 	return &BLAKE2b{}
 }
 
+// NewWithLength creates a new unkeyed Blake2b hashing method with the given output length in bytes.
+// The length must be between 1 and 64.
+func NewWithLength(length int) (*BLAKE2b, error) {
+	if length < 1 || length > _maxhashlength {
+		return nil, fmt.Errorf("hash length must be between 1 and %d bytes", _maxhashlength)
+	}
+
+	return &BLAKE2b{
+		length: length,
+	}, nil
+}
+
+// NewKeyed creates a new keyed Blake2b hashing method with the given output length in bytes.
+// The key must be between 1 and 64 bytes, and the length must be between 1 and 64.
+func NewKeyed(key []byte, length int) (*BLAKE2b, error) {
+	if len(key) == 0 {
+		return nil, errors.New("no key specified")
+	}
+	if len(key) > _maxkeylength {
+		return nil, fmt.Errorf("key must be at most %d bytes", _maxkeylength)
+	}
+	if length < 1 || length > _maxhashlength {
+		return nil, fmt.Errorf("hash length must be between 1 and %d bytes", _maxhashlength)
+	}
+
+	return &BLAKE2b{
+		length: length,
+		key:    append([]byte{}, key...),
+	}, nil
+}
+
 // HashLength returns the length of hashes generated by Hash() in bytes.
-func (*BLAKE2b) HashLength() int {
-	return _hashlength
+func (h *BLAKE2b) HashLength() int {
+	if h.length == 0 {
+		return _hashlength
+	}
+
+	return h.length
 }
 
 // HashName returns the name of this hash.
-func (*BLAKE2b) HashName() string {
-	return "blake2b"
+// The name includes the mode if keyed, and the output length in bits if it is not the default.
+func (h *BLAKE2b) HashName() string {
+	name := "blake2b"
+	if h.key != nil {
+		name += "-keyed"
+	}
+	if h.HashLength() != _hashlength {
+		name += fmt.Sprintf("-%d", h.HashLength()*8)
+	}
+
+	return name
 }
 
 // Hash generates a BLAKE2b hash from input byte arrays.
-func (*BLAKE2b) Hash(data ...[]byte) []byte {
+func (h *BLAKE2b) Hash(data ...[]byte) []byte {
+	if h.key != nil || h.HashLength() != _hashlength {
+		// Parameters have been checked on creation so this cannot error.
+		hash, _ := blake2b.New(h.HashLength(), h.key)
+		for _, d := range data {
+			hash.Write(d)
+		}
+
+		return hash.Sum(nil)
+	}
+
 	var hash [_hashlength]byte
 	if len(data) == 1 {
 		hash = blake2b.Sum256(data[0])
@@ -59,3 +130,51 @@ func (*BLAKE2b) Hash(data ...[]byte) []byte {
 
 	return hash[:]
 }
+
+// NewHasher returns a new streaming BLAKE2b hasher.
+func (h *BLAKE2b) NewHasher() hash.Hash {
+	// Parameters have been checked on creation so this cannot error.
+	hasher, _ := blake2b.New(h.HashLength(), h.key)
+
+	return hasher
+}
+
+// HashPairs hashes pairs of inputs, placing the results in dst.
+// Large batches are split between multiple goroutines.
+func (h *BLAKE2b) HashPairs(dst [][]byte, lefts [][]byte, rights [][]byte) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(dst)/_minparallelpairs {
+		workers = len(dst) / _minparallelpairs
+	}
+	if workers <= 1 {
+		h.hashPairs(dst, lefts, rights)
+
+		return
+	}
+
+	chunkSize := (len(dst) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(dst); start += chunkSize {
+		end := start + chunkSize
+		if end > len(dst) {
+			end = len(dst)
+		}
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+			h.hashPairs(dst[start:end], lefts[start:end], rights[start:end])
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// hashPairs hashes pairs of inputs using a single hasher.
+func (h *BLAKE2b) hashPairs(dst [][]byte, lefts [][]byte, rights [][]byte) {
+	hasher := h.NewHasher()
+	for i := range dst {
+		hasher.Reset()
+		hasher.Write(lefts[i])
+		hasher.Write(rights[i])
+		dst[i] = hasher.Sum(dst[i][:0])
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
 )
 
@@ -73,3 +74,104 @@ func TestMultiHash(t *testing.T) {
 		assert.Equal(t, test.output, output, fmt.Sprintf("failed at test %d", i))
 	}
 }
+
+func TestLengthHash(t *testing.T) {
+	tests := []struct {
+		length int
+		data   []byte
+		name   string
+		output []byte
+	}{
+		{ // 0
+			length: 20,
+			data:   []byte("abc"),
+			name:   "blake2b-160",
+			output: _byteArray("384264f676f39536840523f284921cdc68b6846b"),
+		},
+		{ // 1
+			length: 32,
+			data:   _byteArray("e9e0083e456539e9f6336164cd98700e668178f98af147ef750eb90afcf2f637"),
+			name:   "blake2b",
+			output: _byteArray("92c7a270abba6545cff680c3452f1573b3b672d66f663b4c1d1d3ce7c35b5170"),
+		},
+		{ // 2
+			length: 64,
+			data:   []byte("abc"),
+			name:   "blake2b-512",
+			output: _byteArray("ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"),
+		},
+	}
+
+	for i, test := range tests {
+		hash, err := blake2b.NewWithLength(test.length)
+		require.NoError(t, err)
+		assert.Equal(t, test.name, hash.HashName(), fmt.Sprintf("incorrect name at test %d", i))
+		assert.Equal(t, test.length, hash.HashLength(), fmt.Sprintf("incorrect length at test %d", i))
+		assert.Equal(t, test.output, hash.Hash(test.data), fmt.Sprintf("failed at test %d", i))
+	}
+
+	_, err := blake2b.NewWithLength(65)
+	assert.EqualError(t, err, "hash length must be between 1 and 64 bytes")
+}
+
+func TestKeyedHash(t *testing.T) {
+	// Test vectors from https://github.com/BLAKE2/BLAKE2/blob/master/testvectors/blake2b-kat.txt
+	key := _byteArray("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f")
+	tests := []struct {
+		data   []byte
+		output []byte
+	}{
+		{ // 0
+			data:   []byte{},
+			output: _byteArray("10ebb67700b1868efb4417987acf4690ae9d972fb7a590c2f02871799aaa4786b5e996e8f0f4eb981fc214b005f42d2ff4233499391653df7aefcbc13fc51568"),
+		},
+		{ // 1
+			data:   _byteArray("00"),
+			output: _byteArray("961f6dd1e4dd30f63901690c512e78e4b45e4742ed197c3c5e45c549fd25f2e4187b0bc9fe30492b16b0d0bc4ef9b0f34c7003fac09a5ef1532e69430234cebd"),
+		},
+		{ // 2
+			data:   _byteArray("0001"),
+			output: _byteArray("da2cfbe2d8409a0f38026113884f84b50156371ae304c4430173d08a99d9fb1b983164a3770706d537f49e0c916d9f32b95cc37a95b99d857436f0232c88a965"),
+		},
+	}
+
+	hash, err := blake2b.NewKeyed(key, 64)
+	require.NoError(t, err)
+	assert.Equal(t, "blake2b-keyed-512", hash.HashName())
+	for i, test := range tests {
+		output := hash.Hash(test.data)
+		assert.Equal(t, test.output, output, fmt.Sprintf("failed at test %d", i))
+	}
+
+	hash, err = blake2b.NewKeyed(key, 32)
+	require.NoError(t, err)
+	assert.Equal(t, "blake2b-keyed", hash.HashName())
+	assert.NotEqual(t, blake2b.New().Hash([]byte("Foo")), hash.Hash([]byte("Foo")))
+
+	_, err = blake2b.NewKeyed(nil, 32)
+	assert.EqualError(t, err, "no key specified")
+	_, err = blake2b.NewKeyed(make([]byte, 65), 32)
+	assert.EqualError(t, err, "key must be at most 64 bytes")
+	_, err = blake2b.NewKeyed(key, 0)
+	assert.EqualError(t, err, "hash length must be between 1 and 64 bytes")
+}
+
+func TestHashPairs(t *testing.T) {
+	pairs := 5000
+	lefts := make([][]byte, pairs)
+	rights := make([][]byte, pairs)
+	for i := 0; i < pairs; i++ {
+		lefts[i] = []byte(fmt.Sprintf("left %d", i))
+		rights[i] = []byte(fmt.Sprintf("right %d", i))
+	}
+
+	hash, err := blake2b.NewWithLength(20)
+	require.NoError(t, err)
+	for _, hash := range []*blake2b.BLAKE2b{blake2b.New(), hash} {
+		dst := make([][]byte, pairs)
+		hash.HashPairs(dst, lefts, rights)
+		for i := 0; i < pairs; i++ {
+			require.Equal(t, hash.Hash(lefts[i], rights[i]), dst[i], fmt.Sprintf("failed at pair %d", i))
+		}
+	}
+}
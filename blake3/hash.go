@@ -0,0 +1,94 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blake3 provides hashing using the BLAKE3 system.
+package blake3
+
+import (
+	"errors"
+	"hash"
+
+	"github.com/zeebo/blake3"
+)
+
+const (
+	_hashlength = 32
+	_keylength  = 32
+)
+
+// BLAKE3 is the BLAKE3 hashing method.
+type BLAKE3 struct {
+	// hasher is the initial state from which each hash is calculated.
+	hasher *blake3.Hasher
+	name   string
+}
+
+// New creates a new BLAKE3 hashing method.
+func New() *BLAKE3 {
+	return &BLAKE3{
+		hasher: blake3.New(),
+		name:   "blake3",
+	}
+}
+
+// NewKeyed creates a new BLAKE3 hashing method in keyed mode.
+// The key must be 32 bytes long.
+func NewKeyed(key []byte) (*BLAKE3, error) {
+	if len(key) != _keylength {
+		return nil, errors.New("key must be 32 bytes")
+	}
+	hasher, err := blake3.NewKeyed(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BLAKE3{
+		hasher: hasher,
+		name:   "blake3-keyed",
+	}, nil
+}
+
+// NewDeriveKey creates a new BLAKE3 hashing method in derive key mode.
+// The context should be a hardcoded, globally unique and application-specific string, and is used for domain separation.
+// The name of the hash includes the context, as hashes with different contexts differ.
+func NewDeriveKey(context string) *BLAKE3 {
+	return &BLAKE3{
+		hasher: blake3.NewDeriveKey(context),
+		name:   "blake3-derive-key:" + context,
+	}
+}
+
+// HashLength returns the length of hashes generated by Hash() in bytes.
+func (*BLAKE3) HashLength() int {
+	return _hashlength
+}
+
+// HashName returns the name of this hash.
+func (h *BLAKE3) HashName() string {
+	return h.name
+}
+
+// Hash generates a BLAKE3 hash from input byte arrays.
+func (h *BLAKE3) Hash(data ...[]byte) []byte {
+	hasher := h.hasher.Clone()
+	for _, d := range data {
+		_, _ = hasher.Write(d)
+	}
+
+	return hasher.Sum(nil)
+}
+
+// NewHasher returns a new streaming BLAKE3 hasher.
+func (h *BLAKE3) NewHasher() hash.Hash {
+	return h.hasher.Clone()
+}
@@ -0,0 +1,29 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blake3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashLength(t *testing.T) {
+	assert.Equal(t, _hashlength, New().HashLength(), "incorrect hash length reported")
+}
+
+func TestKeyLength(t *testing.T) {
+	_, err := NewKeyed(make([]byte, _keylength-1))
+	assert.EqualError(t, err, "key must be 32 bytes")
+}
@@ -0,0 +1,117 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blake3_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake3"
+)
+
+// _byteArray is a helper to turn a string in to a byte array
+func _byteArray(input string) []byte {
+	x, err := hex.DecodeString(input)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// _input generates the input used by the BLAKE3 reference test vectors, a repeating sequence of the bytes 0 to 250.
+func _input(length int) []byte {
+	input := make([]byte, length)
+	for i := range input {
+		input[i] = byte(i % 251)
+	}
+	return input
+}
+
+// Test vectors from https://github.com/BLAKE3-team/BLAKE3/blob/master/test_vectors/test_vectors.json
+var (
+	_key     = []byte("whats the Elvish word for friend")
+	_context = "BLAKE3 2019-12-27 16:29:52 test vectors context"
+	vectors  = []struct {
+		inputLen  int
+		hash      []byte
+		keyedHash []byte
+		deriveKey []byte
+	}{
+		{ // 0
+			inputLen:  0,
+			hash:      _byteArray("af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"),
+			keyedHash: _byteArray("92b2b75604ed3c761f9d6f62392c8a9227ad0ea3f09573e783f1498a4ed60d26"),
+			deriveKey: _byteArray("2cc39783c223154fea8dfb7c1b1660f2ac2dcbd1c1de8277b0b0dd39b7e50d7d"),
+		},
+		{ // 1
+			inputLen:  1,
+			hash:      _byteArray("2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"),
+			keyedHash: _byteArray("6d7878dfff2f485635d39013278ae14f1454b8c0a3a2d34bc1ab38228a80c95b"),
+			deriveKey: _byteArray("b3e2e340a117a499c6cf2398a19ee0d29cca2bb7404c73063382693bf66cb06c"),
+		},
+		{ // 2
+			inputLen:  1024,
+			hash:      _byteArray("42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"),
+			keyedHash: _byteArray("75c46f6f3d9eb4f55ecaaee480db732e6c2105546f1e675003687c31719c7ba4"),
+			deriveKey: _byteArray("7356cd7720d5b66b6d0697eb3177d9f8d73a4a5c5e968896eb6a689684302706"),
+		},
+		{ // 3
+			inputLen:  1025,
+			hash:      _byteArray("d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"),
+			keyedHash: _byteArray("357dc55de0c7e382c900fd6e320acc04146be01db6a8ce7210b7189bd664ea69"),
+			deriveKey: _byteArray("effaa245f065fbf82ac186839a249707c3bddf6d3fdda22d1b95a3c970379bcb"),
+		},
+	}
+)
+
+func TestHash(t *testing.T) {
+	hash := blake3.New()
+	assert.Equal(t, "blake3", hash.HashName())
+	for i, test := range vectors {
+		output := hash.Hash(_input(test.inputLen))
+		assert.Equal(t, test.hash, output, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestKeyedHash(t *testing.T) {
+	hash, err := blake3.NewKeyed(_key)
+	require.NoError(t, err)
+	assert.Equal(t, "blake3-keyed", hash.HashName())
+	for i, test := range vectors {
+		output := hash.Hash(_input(test.inputLen))
+		assert.Equal(t, test.keyedHash, output, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestDeriveKeyHash(t *testing.T) {
+	hash := blake3.NewDeriveKey(_context)
+	assert.Equal(t, "blake3-derive-key:"+_context, hash.HashName())
+	for i, test := range vectors {
+		output := hash.Hash(_input(test.inputLen))
+		assert.Equal(t, test.deriveKey, output, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestMultiHash(t *testing.T) {
+	hash := blake3.New()
+	for i, test := range vectors {
+		input := _input(test.inputLen)
+		third := len(input) / 3
+		output := hash.Hash(input[:third], input[third:2*third], input[2*third:])
+		assert.Equal(t, test.hash, output, fmt.Sprintf("failed at test %d", i))
+	}
+}
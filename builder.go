@@ -0,0 +1,354 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Builder builds the root of a Merkle tree from values supplied one at a time, without holding all of the values in memory.
+// Only a frontier of the nodes awaiting their siblings is held, so memory use is proportional to the depth of the tree.
+//
+// The root is the same as that of a MerkleTree created from the same values with the same parameters.  Builders cannot create
+// sorted trees or trees with per-value salts, as both require all of the values up front.
+//
+// If a spill directory is supplied with WithSpillDir() each level of nodes is also written to a uniquely-named file in that
+// directory as it is built, allowing proofs to be generated once the tree is complete.  The files are removed by Close(), or if
+// they cannot be written.
+//
+// A builder is not safe for concurrent use.
+type Builder struct {
+	hash       HashType
+	saltFunc   SaltFunc
+	padding    Padding
+	countMixIn bool
+	arity      int
+	width      uint64
+	hasher     *hasher
+
+	count uint64
+	// frontier are the nodes at each level that are awaiting their siblings.
+	frontier [][][]byte
+	// last is the last leaf, used for padding with PaddingDuplicateLast.
+	last []byte
+	root []byte
+	// depth is the depth of the tree, once it has been finalized.
+	depth int
+	// empties are the roots of subtrees of each height that contain only padding, once the tree has been finalized.
+	empties [][]byte
+
+	spillDir string
+	files    []*os.File
+	writers  []*bufio.Writer
+	// spillErr is the error that caused the spill files to be removed, if any.
+	spillErr error
+}
+
+// _maxValueLength is the maximum length of a value read by Builder.AddFromReader().
+const _maxValueLength = 64 * 1024 * 1024
+
+// NewBuilder creates a new builder.
+func NewBuilder(params ...Parameter) (*Builder, error) {
+	parameters, err := parseAndCheckBuilderParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+
+	return &Builder{
+		hash:       parameters.hash,
+		saltFunc:   parameters.saltFunc,
+		padding:    parameters.padding,
+		countMixIn: parameters.countMixIn,
+		arity:      parameters.arity,
+		width:      parameters.width,
+		hasher:     newHasher(parameters.hash),
+		spillDir:   parameters.spillDir,
+	}, nil
+}
+
+// Count returns the number of values added to the builder.
+func (b *Builder) Count() uint64 {
+	return b.count
+}
+
+// Add adds a value to the tree.
+func (b *Builder) Add(data []byte) error {
+	if b.root != nil {
+		return errors.New("builder is finalized")
+	}
+	salt := saltFor(b.saltFunc, b.count)
+	if err := checkLeaf(b.hash, data, salt); err != nil {
+		return fmt.Errorf("invalid data at index %d: %w", b.count, err)
+	}
+
+	leaf := b.hasher.hashLeaf(nil, data, salt)
+	b.count++
+	b.last = leaf
+
+	return b.push(0, leaf)
+}
+
+// AddFromIterator adds the values returned by the iterator to the tree, until it returns io.EOF.
+func (b *Builder) AddFromIterator(next func() ([]byte, error)) error {
+	for {
+		data, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to obtain data")
+		}
+		if err := b.Add(data); err != nil {
+			return err
+		}
+	}
+}
+
+// AddFromReader adds the values read from the reader to the tree, until the end of the reader.  Each value is preceded by its
+// length as a 4-byte big-endian integer, as written by WriteLengthPrefixed().  Values longer than 64MiB are rejected.
+func (b *Builder) AddFromReader(r io.Reader) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, 4)
+	data := make([]byte, 0)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return errors.Wrap(err, "failed to read length")
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length > _maxValueLength {
+			return fmt.Errorf("value of %d bytes exceeds maximum length of %d bytes", length, _maxValueLength)
+		}
+		if uint32(cap(data)) < length {
+			data = make([]byte, length)
+		}
+		data = data[:length]
+		if _, err := io.ReadFull(br, data); err != nil {
+			return errors.Wrap(err, "failed to read data")
+		}
+		if err := b.Add(data); err != nil {
+			return err
+		}
+	}
+}
+
+// WriteLengthPrefixed writes a value preceded by its length as a 4-byte big-endian integer, as read by Builder.AddFromReader().
+func WriteLengthPrefixed(w io.Writer, data []byte) error {
+	if uint64(len(data)) > 0xffffffff {
+		return errors.New("data too long")
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+
+	return err
+}
+
+// Root finalizes the tree and returns its root.  No more values can be added once the root has been obtained.
+// If the tree mixes its count in to the root this is the root after the count has been mixed in.
+func (b *Builder) Root() ([]byte, error) {
+	if b.root != nil {
+		return b.root, nil
+	}
+	if b.count == 0 {
+		return nil, errors.New("tree must have at least 1 piece of data")
+	}
+
+	arity := arityOf(b.arity)
+	b.depth = depthFor(b.count, arity)
+	if width := depthFor(b.width, arity); width > b.depth {
+		b.depth = width
+	}
+	b.empties = make([][]byte, b.depth+1)
+	b.empties[0] = paddingLeaf(b.padding, b.hash, b.last)
+	for level := 1; level <= b.depth && b.empties[level-1] != nil; level++ {
+		children := make([][]byte, arity)
+		for i := range children {
+			children[i] = b.empties[level-1]
+		}
+		b.empties[level] = b.hasher.hashChildren(nil, children)
+	}
+
+	// Complete the frontier from the bottom up, padding each incomplete set of siblings.
+	for level := 0; level < b.depth; level++ {
+		if level >= len(b.frontier) || len(b.frontier[level]) == 0 {
+			continue
+		}
+		siblings := b.frontier[level]
+		b.frontier[level] = nil
+		var parent []byte
+		if b.empties[level] == nil && uint64(len(siblings)) < arity {
+			// As per PaddingNone, a branch without a right child takes the hash of its left child.
+			parent = siblings[0]
+		} else {
+			for uint64(len(siblings)) < arity {
+				siblings = append(siblings, b.empties[level])
+			}
+			parent = b.hasher.hashChildren(nil, siblings)
+		}
+		if err := b.spill(level+1, parent); err != nil {
+			return nil, err
+		}
+		for len(b.frontier) <= level+1 {
+			b.frontier = append(b.frontier, nil)
+		}
+		b.frontier[level+1] = append(b.frontier[level+1], parent)
+	}
+
+	root := b.frontier[b.depth][0]
+	if b.countMixIn {
+		root = mixInCount(b.hasher, root, b.count)
+	}
+	for i := range b.writers {
+		if err := b.writers[i].Flush(); err != nil {
+			return nil, b.failSpill(errors.Wrap(err, "failed to write spill file"))
+		}
+	}
+	b.root = root
+
+	return root, nil
+}
+
+// GenerateProofWithIndex generates the proof for the data at the given index, as per MerkleTree.GenerateProofWithIndex().
+// The tree must have been finalized with Root(), and built with a spill directory.
+func (b *Builder) GenerateProofWithIndex(index uint64, height int) (*Proof, error) {
+	if b.root == nil {
+		return nil, errors.New("builder is not finalized")
+	}
+	if b.spillDir == "" {
+		return nil, errors.New("proofs require a spill directory")
+	}
+	if index >= b.count {
+		return nil, errors.New("index out of range")
+	}
+	if height < 0 || height > b.depth {
+		return nil, errors.New("height out of range")
+	}
+	if len(b.files) < b.depth-height {
+		return nil, errors.New("spill files are not available")
+	}
+
+	arity := arityOf(b.arity)
+	hashes := make([][]byte, 0, (b.depth-height)*int(arity-1))
+	hash := make([]byte, b.hash.HashLength())
+	position := index
+	width := b.count
+	for level := 0; level < b.depth-height; level++ {
+		first := position - position%arity
+		for j := first; j < first+arity; j++ {
+			if j == position {
+				continue
+			}
+			if j >= width {
+				hashes = append(hashes, b.empties[level])
+
+				continue
+			}
+			if _, err := b.files[level].ReadAt(hash, int64(j)*int64(len(hash))); err != nil {
+				return nil, errors.Wrap(err, "failed to read spill file")
+			}
+			hashes = append(hashes, append([]byte{}, hash...))
+		}
+		position /= arity
+		width = (width + arity - 1) / arity
+	}
+
+	proof := newProof(hashes, index)
+	proof.Arity = b.arity
+	if b.countMixIn && height == 0 {
+		proof.Count = b.count
+	}
+
+	return proof, nil
+}
+
+// Close closes and removes the spill files of the builder.
+func (b *Builder) Close() error {
+	var firstErr error
+	for _, file := range b.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "failed to close spill file")
+		}
+		if err := os.Remove(file.Name()); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "failed to remove spill file")
+		}
+	}
+	b.files = nil
+	b.writers = nil
+
+	return firstErr
+}
+
+// failSpill removes the spill files after an error writing them, so that they are not left behind, and returns the error.
+// Later attempts to spill return the same error, as the levels of the tree are no longer complete.
+func (b *Builder) failSpill(err error) error {
+	_ = b.Close()
+	b.spillErr = err
+
+	return err
+}
+
+// push adds a node to the frontier at the given level, hashing it with its siblings once they are all present.
+func (b *Builder) push(level int, node []byte) error {
+	if err := b.spill(level, node); err != nil {
+		return err
+	}
+	for len(b.frontier) <= level {
+		b.frontier = append(b.frontier, nil)
+	}
+	b.frontier[level] = append(b.frontier[level], node)
+	if uint64(len(b.frontier[level])) < arityOf(b.arity) {
+		return nil
+	}
+
+	parent := b.hasher.hashChildren(nil, b.frontier[level])
+	b.frontier[level] = b.frontier[level][:0]
+
+	return b.push(level+1, parent)
+}
+
+// spill writes a node to the spill file for its level, if the builder has a spill directory.
+func (b *Builder) spill(level int, node []byte) error {
+	if b.spillDir == "" {
+		return nil
+	}
+	if b.spillErr != nil {
+		return b.spillErr
+	}
+	for len(b.files) <= level {
+		file, err := os.CreateTemp(b.spillDir, fmt.Sprintf("level-%d-*", len(b.files)))
+		if err != nil {
+			return b.failSpill(errors.Wrap(err, "failed to create spill file"))
+		}
+		b.files = append(b.files, file)
+		b.writers = append(b.writers, bufio.NewWriter(file))
+	}
+	if _, err := b.writers[level].Write(node); err != nil {
+		return b.failSpill(errors.Wrap(err, "failed to write spill file"))
+	}
+
+	return nil
+}
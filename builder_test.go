@@ -0,0 +1,223 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		params []Parameter
+	}{
+		{},
+		{
+			params: []Parameter{WithHashType(keccak256.New()), WithSalt(true)},
+		},
+		{
+			params: []Parameter{WithPadding(PaddingEmptyHash), WithCountMixIn(true)},
+		},
+		{
+			params: []Parameter{WithPadding(PaddingDuplicateLast)},
+		},
+		{
+			params: []Parameter{WithPadding(PaddingNone)},
+		},
+		{
+			params: []Parameter{WithArity(4)},
+		},
+		{
+			params: []Parameter{WithArity(8), WithWidth(100)},
+		},
+	}
+
+	for i, test := range tests {
+		for _, values := range []int{1, 2, 3, 8, 13, 64, 100} {
+			name := fmt.Sprintf("failed at test %d with %d values", i, values)
+			data := diffData(values)
+			expected, err := NewTree(append([]Parameter{WithData(data)}, test.params...)...)
+			require.NoError(t, err, name)
+
+			builder, err := NewBuilder(append([]Parameter{WithSpillDir(t.TempDir())}, test.params...)...)
+			require.NoError(t, err, name)
+			for _, value := range data {
+				require.NoError(t, builder.Add(value), name)
+			}
+			assert.Equal(t, uint64(values), builder.Count(), name)
+			root, err := builder.Root()
+			require.NoError(t, err, name)
+			assert.Equal(t, expected.Root(), root, name)
+
+			for index := range data {
+				proof, err := builder.GenerateProofWithIndex(uint64(index), 0)
+				require.NoError(t, err, name)
+				expectedProof, err := expected.GenerateProofWithIndex(uint64(index), 0)
+				require.NoError(t, err, name)
+				assert.Equal(t, expectedProof, proof, fmt.Sprintf("%s at index %d", name, index))
+			}
+			if values > 1 {
+				proof, err := builder.GenerateProofWithIndex(uint64(values-1), 1)
+				require.NoError(t, err, name)
+				expectedProof, err := expected.GenerateProofWithIndex(uint64(values-1), 1)
+				require.NoError(t, err, name)
+				assert.Equal(t, expectedProof, proof, name)
+			}
+			require.NoError(t, builder.Close(), name)
+		}
+	}
+}
+
+func TestBuilderSources(t *testing.T) {
+	data := diffData(1000)
+	expected, err := NewTree(WithData(data))
+	require.NoError(t, err)
+
+	builder, err := NewBuilder()
+	require.NoError(t, err)
+	next := 0
+	err = builder.AddFromIterator(func() ([]byte, error) {
+		if next == len(data) {
+			return nil, io.EOF
+		}
+		next++
+
+		return data[next-1], nil
+	})
+	require.NoError(t, err)
+	root, err := builder.Root()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Root(), root)
+	// The frontier holds at most one node per level.
+	assert.LessOrEqual(t, len(builder.frontier), 11)
+
+	buf := new(bytes.Buffer)
+	for _, value := range data {
+		require.NoError(t, WriteLengthPrefixed(buf, value))
+	}
+	builder, err = NewBuilder()
+	require.NoError(t, err)
+	require.NoError(t, builder.AddFromReader(buf))
+	root, err = builder.Root()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Root(), root)
+}
+
+func TestBuilderSpill(t *testing.T) {
+	dir := t.TempDir()
+	builder, err := NewBuilder(WithSpillDir(dir))
+	require.NoError(t, err)
+	for _, value := range diffData(5) {
+		require.NoError(t, builder.Add(value))
+	}
+	_, err = builder.Root()
+	require.NoError(t, err)
+
+	// Only nodes that are not padding are spilled.
+	require.Len(t, builder.files, 4)
+	for level, nodes := range []int{5, 3, 2, 1} {
+		info, err := os.Stat(builder.files[level].Name())
+		require.NoError(t, err)
+		assert.Equal(t, int64(nodes*32), info.Size(), fmt.Sprintf("failed at level %d", level))
+	}
+
+	// Another builder using the same directory does not overwrite the spill files.
+	other, err := NewBuilder(WithSpillDir(dir))
+	require.NoError(t, err)
+	for _, value := range diffData(5, 2) {
+		require.NoError(t, other.Add(value))
+	}
+	_, err = other.Root()
+	require.NoError(t, err)
+	expected, err := NewTree(WithData(diffData(5)))
+	require.NoError(t, err)
+	for i := range expected.Data {
+		proof, err := builder.GenerateProofWithIndex(uint64(i), 0)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		verified, err := VerifyProof(expected.Data[i], false, proof, [][]byte{expected.Root()})
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at index %d", i))
+	}
+	require.NoError(t, other.Close())
+
+	require.NoError(t, builder.Close())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	_, err = builder.GenerateProofWithIndex(0, 0)
+	assert.EqualError(t, err, "spill files are not available")
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := NewBuilder(WithData(diffData(2)))
+	assert.EqualError(t, err, "problem with parameters: builder does not use the data parameter")
+	_, err = NewBuilder(WithRandomSalts(true))
+	assert.EqualError(t, err, "problem with parameters: builder does not use per-value salts")
+	_, err = NewBuilder(WithSorted(true))
+	assert.EqualError(t, err, "problem with parameters: builder cannot create sorted trees")
+	_, err = NewBuilder(WithArity(3))
+	assert.EqualError(t, err, "problem with parameters: arity must be 2, 4, 8 or 16")
+	_, err = NewBuilder(WithArity(4), WithPadding(PaddingNone))
+	assert.EqualError(t, err, "problem with parameters: unbalanced trees must have an arity of 2")
+	_, err = NewBuilder(WithValues(2))
+	assert.EqualError(t, err, "problem with parameters: builder does not use proof parameters")
+	_, err = NewTree(WithData(diffData(2)), WithSpillDir("/tmp"))
+	assert.EqualError(t, err, "problem with parameters: merkle tree does not use the spill directory parameter")
+
+	builder, err := NewBuilder()
+	require.NoError(t, err)
+	_, err = builder.Root()
+	assert.EqualError(t, err, "tree must have at least 1 piece of data")
+	_, err = builder.GenerateProofWithIndex(0, 0)
+	assert.EqualError(t, err, "builder is not finalized")
+	err = builder.AddFromIterator(func() ([]byte, error) { return nil, errors.New("unavailable") })
+	assert.EqualError(t, err, "failed to obtain data: unavailable")
+	err = builder.AddFromReader(bytes.NewReader([]byte{0x00, 0x00}))
+	assert.EqualError(t, err, "failed to read length: unexpected EOF")
+	err = builder.AddFromReader(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x02, 0x01}))
+	assert.EqualError(t, err, "failed to read data: unexpected EOF")
+	err = builder.AddFromReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	assert.EqualError(t, err, "value of 4294967295 bytes exceeds maximum length of 67108864 bytes")
+
+	require.NoError(t, builder.Add([]byte("value")))
+	_, err = builder.Root()
+	require.NoError(t, err)
+	assert.EqualError(t, builder.Add([]byte("value")), "builder is finalized")
+	_, err = builder.GenerateProofWithIndex(0, 0)
+	assert.EqualError(t, err, "proofs require a spill directory")
+
+	builder, err = NewBuilder(WithSpillDir(t.TempDir()))
+	require.NoError(t, err)
+	require.NoError(t, builder.Add([]byte("value")))
+	_, err = builder.Root()
+	require.NoError(t, err)
+	_, err = builder.GenerateProofWithIndex(1, 0)
+	assert.EqualError(t, err, "index out of range")
+	_, err = builder.GenerateProofWithIndex(0, 1)
+	assert.EqualError(t, err, "height out of range")
+	require.NoError(t, builder.Close())
+
+	builder, err = NewBuilder(WithSpillDir("/nonexistent/directory"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, builder.Add([]byte("value")), "failed to create spill file")
+	assert.ErrorContains(t, builder.Add([]byte("value")), "failed to create spill file")
+}
@@ -0,0 +1,32 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/binary"
+)
+
+// mixInCount mixes the number of values in to the root of a tree, as per SSZ's mix_in_length: the root is hashed with the count
+// as a 32-byte little-endian value.  Hash types that operate on field elements read their inputs as big-endian, so for them the
+// count is a 32-byte big-endian value, which is always inside the field.
+func mixInCount(hasher *hasher, root []byte, count uint64) []byte {
+	countBytes := make([]byte, 32)
+	if hasher.fieldElements {
+		binary.BigEndian.PutUint64(countBytes[24:], count)
+	} else {
+		binary.LittleEndian.PutUint64(countBytes, count)
+	}
+
+	return hasher.hashBranch(nil, root, countBytes)
+}
@@ -0,0 +1,198 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/mimc"
+	"github.com/wealdtech/go-merkletree/v2/poseidon"
+)
+
+func TestCountMixIn(t *testing.T) {
+	hashType := keccak256.New()
+	leafHashes := [][]byte{hashType.Hash([]byte("Foo")), hashType.Hash([]byte("Bar")), hashType.Hash([]byte("Baz"))}
+	paddedLeafHashes := append(append([][]byte{}, leafHashes...), make([]byte, 32))
+
+	tree, err := NewTree(WithLeafHashes(leafHashes), WithHashType(hashType))
+	require.NoError(t, err)
+	paddedTree, err := NewTree(WithLeafHashes(paddedLeafHashes), WithHashType(hashType))
+	require.NoError(t, err)
+	require.Equal(t, tree.Root(), paddedTree.Root())
+
+	mixedTree, err := NewTree(WithLeafHashes(leafHashes), WithHashType(hashType), WithCountMixIn(true))
+	require.NoError(t, err)
+	mixedPaddedTree, err := NewTree(WithLeafHashes(paddedLeafHashes), WithHashType(hashType), WithCountMixIn(true))
+	require.NoError(t, err)
+	require.NotEqual(t, mixedTree.Root(), mixedPaddedTree.Root())
+
+	// SSZ mix_in_length.
+	count := make([]byte, 32)
+	count[0] = 3
+	require.Equal(t, hashType.Hash(tree.Root(), count), mixedTree.Root())
+	require.Equal(t, tree.Root(), mixedTree.Nodes[1])
+}
+
+func TestCountMixInProofs(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux"), []byte("Quux")}
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()), WithCountMixIn(true))
+	require.NoError(t, err)
+
+	for i := range data {
+		proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		require.Equal(t, uint64(5), proof.Count, fmt.Sprintf("failed at index %d", i))
+		verified, err := VerifyProofUsing(data[i], false, proof, [][]byte{tree.Root()}, tree.Hash)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at index %d", i))
+		verified, err = VerifyProofUsing(data[i], false, proof, tree.Pollard(0), tree.Hash)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at index %d", i))
+
+		// Proofs with an altered count fail.
+		proof.Count = 8
+		verified, err = VerifyProofUsing(data[i], false, proof, [][]byte{tree.Root()}, tree.Hash)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.False(t, verified, fmt.Sprintf("failed at index %d", i))
+
+		// Proofs without the count fail.
+		proof.Count = 0
+		verified, err = VerifyProofUsing(data[i], false, proof, [][]byte{tree.Root()}, tree.Hash)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.False(t, verified, fmt.Sprintf("failed at index %d", i))
+	}
+
+	// Proofs for indices outside the count fail.
+	proof, err := tree.GenerateProofWithIndex(4, 0)
+	require.NoError(t, err)
+	proof.Index = 5
+	verified, err := VerifyProofUsingLeafHash(tree.Nodes[len(tree.Nodes)/2+5], proof, [][]byte{tree.Root()}, tree.Hash)
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Proofs against pollards do not include the count.
+	proof, err = tree.GenerateProofWithIndex(0, 1)
+	require.NoError(t, err)
+	require.Zero(t, proof.Count)
+	verified, err = VerifyProofUsing(data[0], false, proof, tree.Pollard(1), tree.Hash)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{0, 3, 4})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), multiProof.Count)
+	verified, err = multiProof.Verify([][]byte{data[0], data[3], data[4]}, tree.Root())
+	require.NoError(t, err)
+	assert.True(t, verified)
+	verified, err = VerifyMultiProofUsing([][]byte{data[0], data[3], data[4]}, false, multiProof, tree.Root(), tree.Hash)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	multiProof.Count = 4
+	verified, err = multiProof.Verify([][]byte{data[0], data[3], data[4]}, tree.Root())
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	disclosure, err := tree.GenerateDisclosureWithIndex(2)
+	require.NoError(t, err)
+	verified, err = disclosure.Verify(tree.Root(), LayerOf(tree))
+	require.NoError(t, err)
+	assert.True(t, verified)
+}
+
+func TestCountMixInArity(t *testing.T) {
+	for _, arity := range []int{4, 8, 16} {
+		for _, values := range []int{1, 5, 17, 40} {
+			data := make([][]byte, values)
+			for i := range data {
+				data[i] = []byte(fmt.Sprintf("value %d", i))
+			}
+			tree, err := NewTree(WithData(data), WithArity(arity), WithCountMixIn(true))
+			require.NoError(t, err, fmt.Sprintf("failed at arity %d values %d", arity, values))
+
+			indices := []uint64{0, uint64(values - 1), uint64(values / 2)}
+			multiProof, err := tree.GenerateMultiProofWithIndices(indices)
+			require.NoError(t, err, fmt.Sprintf("failed at arity %d values %d", arity, values))
+			proofData := [][]byte{data[indices[0]], data[indices[1]], data[indices[2]]}
+			verified, err := multiProof.Verify(proofData, tree.Root())
+			require.NoError(t, err, fmt.Sprintf("failed at arity %d values %d", arity, values))
+			assert.True(t, verified, fmt.Sprintf("failed at arity %d values %d", arity, values))
+
+			// The multiproof survives being rebuilt from its fields.
+			rebuilt, err := NewMultiProof(
+				WithHashes(multiProof.Hashes),
+				WithIndices(multiProof.Indices),
+				WithValues(multiProof.Values),
+				WithCount(multiProof.Count),
+				WithArity(multiProof.Arity),
+			)
+			require.NoError(t, err, fmt.Sprintf("failed at arity %d values %d", arity, values))
+			verified, err = rebuilt.Verify(proofData, tree.Root())
+			require.NoError(t, err, fmt.Sprintf("failed at arity %d values %d", arity, values))
+			assert.True(t, verified, fmt.Sprintf("failed at arity %d values %d", arity, values))
+		}
+	}
+}
+
+func TestCountMixInFieldElements(t *testing.T) {
+	data := make([][]byte, 60)
+	for i := range data {
+		data[i] = []byte{byte(i + 1)}
+	}
+
+	for _, hashType := range []fieldElementHashType{poseidon.NewFieldElements(), mimc.New()} {
+		name := hashType.HashName()
+		tree, err := NewTree(WithData(data), WithHashType(hashType), WithCountMixIn(true))
+		require.NoError(t, err, name)
+
+		// The count is mixed in as a field element.
+		count := make([]byte, 32)
+		count[31] = 60
+		expected, err := hashType.HashFieldElements(tree.Nodes[1], count)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, tree.Root(), name)
+
+		proof, err := tree.GenerateProofWithIndex(59, 0)
+		require.NoError(t, err, name)
+		verified, err := VerifyProofUsing(data[59], false, proof, [][]byte{tree.Root()}, hashType)
+		require.NoError(t, err, name)
+		assert.True(t, verified, name)
+	}
+}
+
+func TestCountMixInEncoding(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+		WithCountMixIn(true),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	assert.True(t, newTree.CountMixIn)
+	assert.Equal(t, tree.Root(), newTree.Root())
+	assert.Contains(t, newTree.DOT(nil, nil), `1->0 [label="+3"];`)
+
+	_, err = NewTree(WithData([][]byte{[]byte("Foo")}), WithCount(1))
+	assert.EqualError(t, err, "problem with parameters: merkle tree does not use the count parameter")
+	_, err = NewMultiProof(WithValues(4), WithIndices([]uint64{0}), WithCount(5))
+	assert.EqualError(t, err, "problem with parameters: count cannot be greater than the number of leaves")
+}
@@ -0,0 +1,111 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// NodesFunc returns the hashes of the nodes at the given indices of a Merkle tree, with indices as per the tree's Nodes.  It is
+// generally used to obtain nodes from a remote party; MerkleTree.NodesAt() provides the nodes of a local tree.
+type NodesFunc func(indices []uint64) ([][]byte, error)
+
+// NodesAt returns the hashes of the nodes at the given indices of the tree.
+func (t *MerkleTree) NodesAt(indices []uint64) ([][]byte, error) {
+	hashes := make([][]byte, len(indices))
+	for i, index := range indices {
+		if index == 0 || index >= uint64(len(t.Nodes)) {
+			return nil, errors.New("index out of range")
+		}
+		hashes[i] = t.Nodes[index]
+	}
+
+	return hashes, nil
+}
+
+// Diff returns the indices of the leaves that differ between two trees, in ascending order.
+// The trees must have the same hash type, arity and number of leaves including padding.  Subtrees with the same hash are not
+// walked, so the number of comparisons is proportional to the number of differences multiplied by the depth of the tree.
+// Sorted trees are compared by position in the tree rather than by the position of the data as originally supplied.
+func Diff(a, b *MerkleTree) ([]uint64, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("no tree specified")
+	}
+	if a.Hash.HashName() != b.Hash.HashName() || a.arity() != b.arity() || len(a.Nodes) != len(b.Nodes) {
+		return nil, errors.New("trees have different shapes")
+	}
+
+	limit := a.values()
+	if b.values() > limit {
+		limit = b.values()
+	}
+
+	return a.diff(b.NodesAt, limit)
+}
+
+// DiffRemote returns the indices of the leaves that differ between the tree and a remote tree, in ascending order.
+// The remote tree must have the same hash type, arity and number of leaves including padding as the tree.  The remote nodes are
+// requested one level at a time, so there is a request for each level of the tree down to the deepest difference.
+// As the number of values in the remote tree is unknown, padding leaves are included in the result if they differ.
+func DiffRemote(t *MerkleTree, remote NodesFunc) ([]uint64, error) {
+	if t == nil {
+		return nil, errors.New("no tree specified")
+	}
+	if remote == nil {
+		return nil, errors.New("no remote nodes function specified")
+	}
+
+	return t.diff(remote, uint64(len(t.Nodes))-t.leafOffset())
+}
+
+// diff walks the tree top-down against the remote nodes, returning the indices of differing leaves below the limit.
+func (t *MerkleTree) diff(remote NodesFunc, limit uint64) ([]uint64, error) {
+	arity := t.arity()
+	leafOffset := t.leafOffset()
+
+	diffs := make([]uint64, 0)
+	candidates := []uint64{1}
+	for len(candidates) > 0 {
+		hashes, err := remote(candidates)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to obtain remote nodes")
+		}
+		if len(hashes) != len(candidates) {
+			return nil, errors.New("incorrect number of remote nodes")
+		}
+
+		next := make([]uint64, 0)
+		for i, index := range candidates {
+			if bytes.Equal(t.Nodes[index], hashes[i]) {
+				continue
+			}
+			if index >= leafOffset {
+				if index-leafOffset < limit {
+					diffs = append(diffs, index-leafOffset)
+				}
+
+				continue
+			}
+			first := firstChild(index, arity)
+			for j := first; j < first+arity; j++ {
+				next = append(next, j)
+			}
+		}
+		candidates = next
+	}
+
+	return diffs, nil
+}
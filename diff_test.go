@@ -0,0 +1,176 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func diffData(values int, changes ...int) [][]byte {
+	data := make([][]byte, values)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+	for _, change := range changes {
+		data[change] = []byte(fmt.Sprintf("changed value %d", change))
+	}
+
+	return data
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		aValues int
+		bValues int
+		changes []int
+		arity   int
+		padding Padding
+		diffs   []uint64
+	}{
+		{
+			aValues: 1,
+			bValues: 1,
+			diffs:   []uint64{},
+		},
+		{
+			aValues: 1,
+			bValues: 1,
+			changes: []int{0},
+			diffs:   []uint64{0},
+		},
+		{
+			aValues: 10,
+			bValues: 10,
+			diffs:   []uint64{},
+		},
+		{
+			aValues: 10,
+			bValues: 10,
+			changes: []int{0, 3, 9},
+			diffs:   []uint64{0, 3, 9},
+		},
+		{
+			aValues: 10,
+			bValues: 12,
+			changes: []int{5},
+			diffs:   []uint64{5, 10, 11},
+		},
+		{
+			aValues: 10,
+			bValues: 10,
+			changes: []int{9},
+			padding: PaddingDuplicateLast,
+			diffs:   []uint64{9},
+		},
+		{
+			aValues: 9,
+			bValues: 10,
+			padding: PaddingNone,
+			diffs:   []uint64{9},
+		},
+		{
+			aValues: 20,
+			bValues: 20,
+			changes: []int{1, 2, 17},
+			arity:   4,
+			diffs:   []uint64{1, 2, 17},
+		},
+	}
+
+	for i, test := range tests {
+		a, err := NewTree(WithData(diffData(test.aValues)), WithArity(test.arity), WithPadding(test.padding))
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		b, err := NewTree(WithData(diffData(test.bValues, test.changes...)), WithArity(test.arity), WithPadding(test.padding))
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		diffs, err := Diff(a, b)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, test.diffs, diffs, fmt.Sprintf("failed at test %d", i))
+
+		diffs, err = Diff(b, a)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, test.diffs, diffs, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestDiffRemote(t *testing.T) {
+	a, err := NewTree(WithData(diffData(1000)))
+	require.NoError(t, err)
+	b, err := NewTree(WithData(diffData(1000, 17, 500)))
+	require.NoError(t, err)
+
+	requests := 0
+	comparisons := 0
+	remote := func(indices []uint64) ([][]byte, error) {
+		requests++
+		comparisons += len(indices)
+
+		return b.NodesAt(indices)
+	}
+
+	diffs, err := DiffRemote(a, remote)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{17, 500}, diffs)
+	// One request per level, and two nodes per level for each difference below the root.
+	assert.Equal(t, 11, requests)
+	assert.LessOrEqual(t, comparisons, 1+2*2*10)
+
+	// Padding leaves are reported if they differ.
+	c, err := NewTree(WithData(diffData(1000)), WithPadding(PaddingEmptyHash))
+	require.NoError(t, err)
+	diffs, err = DiffRemote(a, c.NodesAt)
+	require.NoError(t, err)
+	assert.Len(t, diffs, 24)
+	assert.Equal(t, uint64(1000), diffs[0])
+}
+
+func TestDiffErrors(t *testing.T) {
+	a, err := NewTree(WithData(diffData(4)))
+	require.NoError(t, err)
+	b, err := NewTree(WithData(diffData(5)))
+	require.NoError(t, err)
+	c, err := NewTree(WithData(diffData(4)), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+	d, err := NewTree(WithData(diffData(4)), WithArity(4))
+	require.NoError(t, err)
+
+	_, err = Diff(a, nil)
+	assert.EqualError(t, err, "no tree specified")
+	_, err = Diff(a, b)
+	assert.EqualError(t, err, "trees have different shapes")
+	_, err = Diff(a, c)
+	assert.EqualError(t, err, "trees have different shapes")
+	_, err = Diff(a, d)
+	assert.EqualError(t, err, "trees have different shapes")
+
+	_, err = DiffRemote(nil, a.NodesAt)
+	assert.EqualError(t, err, "no tree specified")
+	_, err = DiffRemote(a, nil)
+	assert.EqualError(t, err, "no remote nodes function specified")
+	_, err = DiffRemote(a, func([]uint64) ([][]byte, error) { return nil, errors.New("unavailable") })
+	assert.EqualError(t, err, "failed to obtain remote nodes: unavailable")
+	_, err = DiffRemote(a, func([]uint64) ([][]byte, error) { return nil, nil })
+	assert.EqualError(t, err, "incorrect number of remote nodes")
+	_, err = DiffRemote(b, a.NodesAt)
+	assert.EqualError(t, err, "failed to obtain remote nodes: index out of range")
+
+	_, err = a.NodesAt([]uint64{0})
+	assert.EqualError(t, err, "index out of range")
+}
@@ -0,0 +1,96 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// _randomSaltLength is the length of salts generated by WithRandomSalts().
+const _randomSaltLength = 32
+
+// _randomSaltAttempts is the number of salts generated by WithRandomSalts() for each value before giving up on finding one that
+// is accepted by the hash type.
+const _randomSaltAttempts = 128
+
+// Disclosure is a bundle that reveals a single value of a Merkle tree, along with its salt and proof, without revealing any other
+// values.  When the tree has random per-value salts the other values cannot be brute-forced from the hashes in the proof.
+type Disclosure struct {
+	// Value is the value being disclosed.
+	Value []byte `json:"value"`
+	// Salt is the salt of the value, or nil if the tree is not salted.
+	Salt []byte `json:"salt,omitempty"`
+	// Proof is the proof of the value.
+	Proof *Proof `json:"proof"`
+}
+
+// GenerateDisclosure generates the disclosure for a piece of data.
+// If the data is not present in the tree, or is present more than once, this will return an error.
+func (t *MerkleTree) GenerateDisclosure(data []byte) (*Disclosure, error) {
+	index, err := t.indexOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.GenerateDisclosureWithIndex(index)
+}
+
+// GenerateDisclosureWithIndex generates the disclosure for the data at the given index.
+// If the index is out of range this will return an error.
+func (t *MerkleTree) GenerateDisclosureWithIndex(index uint64) (*Disclosure, error) {
+	if t.Data == nil {
+		return nil, errors.New("tree does not contain data")
+	}
+	proof, err := t.generateProof(index, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Disclosure{
+		Value: t.Data[index],
+		Salt:  t.leafSalt(index),
+		Proof: proof,
+	}, nil
+}
+
+// Verify verifies a disclosure against the root of a tree with the given configuration, as returned by LayerOf().
+// The salt of the disclosure is only used if the tree has per-value salts, or is sorted and salted by index; otherwise the salt
+// is generated from the index of the proof.
+//
+// This returns true if the disclosure is verified, otherwise false.
+func (d *Disclosure) Verify(root []byte, layer *Layer) (bool, error) {
+	if d.Proof == nil {
+		return false, errors.New("no proof specified")
+	}
+	if layer == nil || layer.Hash == nil {
+		return false, errors.New("no layer specified")
+	}
+	salt, err := layer.leafSalt(d.Proof.Index, d.Salt)
+	if err != nil {
+		return false, err
+	}
+	if err := checkLeaf(layer.Hash, d.Value, salt); err != nil {
+		return false, err
+	}
+	if err := checkHashes(layer.Hash, d.Proof.Hashes); err != nil {
+		return false, err
+	}
+	if !d.Proof.valid() {
+		return false, nil
+	}
+
+	return bytes.Equal(generateProofHash(d.Value, salt, d.Proof, layer.Sorted, layer.Hash), root), nil
+}
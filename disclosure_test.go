@@ -0,0 +1,227 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/poseidon"
+)
+
+func TestDisclosure(t *testing.T) {
+	claims := [][]byte{[]byte("name=Alice"), []byte("age=42"), []byte("country=GB")}
+
+	for _, sorted := range []bool{false, true} {
+		data := append([][]byte{}, claims...)
+		tree, err := NewTree(
+			WithData(data),
+			WithHashType(keccak256.New()),
+			WithRandomSalts(true),
+			WithSorted(sorted),
+		)
+		require.NoError(t, err)
+		require.Len(t, tree.Salts, len(claims))
+
+		for _, claim := range claims {
+			disclosure, err := tree.GenerateDisclosure(claim)
+			require.NoError(t, err, fmt.Sprintf("failed at claim %s", claim))
+			require.Equal(t, claim, disclosure.Value)
+			require.Len(t, disclosure.Salt, 32)
+
+			// Disclosures survive transmission.
+			exported, err := json.Marshal(disclosure)
+			require.NoError(t, err)
+			var received Disclosure
+			require.NoError(t, json.Unmarshal(exported, &received))
+
+			verified, err := received.Verify(tree.Root(), LayerOf(tree))
+			require.NoError(t, err, fmt.Sprintf("failed at claim %s", claim))
+			assert.True(t, verified, fmt.Sprintf("failed at claim %s", claim))
+
+			// Index salting cannot verify the value.
+			verified, err = VerifyProofUsing(claim, true, disclosure.Proof, [][]byte{tree.Root()}, keccak256.New())
+			require.NoError(t, err)
+			assert.False(t, verified, fmt.Sprintf("failed at claim %s", claim))
+
+			received.Value = []byte("age=18")
+			verified, err = received.Verify(tree.Root(), LayerOf(tree))
+			require.NoError(t, err, fmt.Sprintf("failed at claim %s", claim))
+			assert.False(t, verified, fmt.Sprintf("failed at claim %s", claim))
+		}
+	}
+
+	// Random salts differ between trees.
+	tree1, err := NewTree(WithData(claims), WithRandomSalts(true))
+	require.NoError(t, err)
+	tree2, err := NewTree(WithData(claims), WithRandomSalts(true))
+	require.NoError(t, err)
+	assert.NotEqual(t, tree1.Root(), tree2.Root())
+}
+
+func TestDisclosureSuppliedSalts(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+	salts := [][]byte{
+		bytes.Repeat([]byte{0x01}, 32),
+		bytes.Repeat([]byte{0x02}, 32),
+		bytes.Repeat([]byte{0x03}, 32),
+	}
+
+	tree, err := NewTree(WithData(data), WithSalts(salts))
+	require.NoError(t, err)
+
+	expected, err := NewTree(WithData([][]byte{
+		append([]byte("Foo"), salts[0]...),
+		append([]byte("Bar"), salts[1]...),
+		append([]byte("Baz"), salts[2]...),
+	}))
+	require.NoError(t, err)
+	require.Equal(t, expected.Root(), tree.Root())
+
+	disclosure, err := tree.GenerateDisclosureWithIndex(1)
+	require.NoError(t, err)
+	require.Equal(t, salts[1], disclosure.Salt)
+	verified, err := disclosure.Verify(tree.Root(), LayerOf(tree))
+	require.NoError(t, err)
+	require.True(t, verified)
+
+	// Salts are retained when exported.
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	require.Equal(t, salts, newTree.Salts)
+
+	_, err = tree.GenerateMultiProofWithIndices([]uint64{0, 1})
+	require.EqualError(t, err, "multiproofs are not supported for trees with per-value salts")
+}
+
+func TestDisclosureIndexSalt(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux"), []byte("Quux")}
+
+	for _, salt := range []bool{false, true} {
+		for _, sorted := range []bool{false, true} {
+			tree, err := NewTree(WithData(data), WithSalt(salt), WithSorted(sorted))
+			require.NoError(t, err)
+			for i := range data {
+				disclosure, err := tree.GenerateDisclosureWithIndex(uint64(i))
+				require.NoError(t, err)
+				verified, err := disclosure.Verify(tree.Root(), LayerOf(tree))
+				require.NoError(t, err)
+				assert.True(t, verified, fmt.Sprintf("failed at index %d with salt %t sorted %t", i, salt, sorted))
+			}
+		}
+	}
+}
+
+func TestDisclosureFieldElements(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}, {0x04}}
+
+	tree, err := NewTree(WithData(data), WithHashType(poseidon.NewFieldElements()), WithRandomSalts(true))
+	require.NoError(t, err)
+	require.NotEmpty(t, tree.Root())
+	for i := range data {
+		require.NoError(t, tree.Hash.(CheckedHashType).CheckData(tree.Salts[i]), fmt.Sprintf("failed at index %d", i))
+		disclosure, err := tree.GenerateDisclosureWithIndex(uint64(i))
+		require.NoError(t, err)
+		verified, err := disclosure.Verify(tree.Root(), LayerOf(tree))
+		require.NoError(t, err)
+		assert.True(t, verified, fmt.Sprintf("failed at index %d", i))
+	}
+}
+
+func TestDisclosureErrors(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar")}
+
+	tests := []struct {
+		params []Parameter
+		err    string
+	}{
+		{
+			params: []Parameter{WithData(data), WithRandomSalts(true), WithSalts([][]byte{{0x01}, {0x02}})},
+			err:    "problem with parameters: cannot use both random and supplied salts",
+		},
+		{
+			params: []Parameter{WithData(data), WithRandomSalts(true), WithSalt(true)},
+			err:    "problem with parameters: cannot use both per-value salts and index salts",
+		},
+		{
+			params: []Parameter{WithData(data), WithSalts([][]byte{{0x01}})},
+			err:    "problem with parameters: salts must be the same length as data",
+		},
+		{
+			params: []Parameter{WithData(data), WithSalts([][]byte{bytes.Repeat([]byte{0x01}, 32), {}})},
+			err:    "problem with parameters: missing salt at index 1",
+		},
+		{
+			params: []Parameter{WithData(data), WithSalts([][]byte{bytes.Repeat([]byte{0x01}, 32), {0x02}})},
+			err:    "problem with parameters: salt at index 1 must be 32 bytes",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := NewTree(test.params...)
+		assert.EqualError(t, err, test.err, fmt.Sprintf("failed at test %d", i))
+	}
+
+	tree, err := NewTree(WithData(data), WithRandomSalts(true))
+	require.NoError(t, err)
+	_, err = tree.GenerateDisclosure([]byte("Baz"))
+	require.EqualError(t, err, "data not found")
+	_, err = tree.GenerateDisclosureWithIndex(2)
+	require.EqualError(t, err, "index out of range")
+	_, err = (&Disclosure{Value: data[0]}).Verify(tree.Root(), LayerOf(tree))
+	require.EqualError(t, err, "no proof specified")
+	disclosure, err := tree.GenerateDisclosureWithIndex(0)
+	require.NoError(t, err)
+	_, err = disclosure.Verify(tree.Root(), nil)
+	require.EqualError(t, err, "no layer specified")
+}
+
+func TestDisclosureForgery(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+
+	// Bytes of the salt cannot be moved in to the value of an index-salted tree.
+	tree, err := NewTree(WithData(data), WithSalt(true))
+	require.NoError(t, err)
+	disclosure, err := tree.GenerateDisclosureWithIndex(0)
+	require.NoError(t, err)
+	forged := &Disclosure{Value: []byte("Foo\x00"), Salt: []byte{0x00, 0x00, 0x00}, Proof: disclosure.Proof}
+	verified, err := forged.Verify(tree.Root(), LayerOf(tree))
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Nor in to the value of a sorted index-salted tree.
+	tree, err = NewTree(WithData(data), WithSalt(true), WithSorted(true))
+	require.NoError(t, err)
+	disclosure, err = tree.GenerateDisclosure(data[0])
+	require.NoError(t, err)
+	forged = &Disclosure{Value: append([]byte("Foo"), disclosure.Salt[0]), Salt: disclosure.Salt[1:], Proof: disclosure.Proof}
+	_, err = forged.Verify(tree.Root(), LayerOf(tree))
+	require.EqualError(t, err, "salt has incorrect length")
+
+	// Nor in to the value of a tree with per-value salts.
+	tree, err = NewTree(WithData(data), WithRandomSalts(true))
+	require.NoError(t, err)
+	disclosure, err = tree.GenerateDisclosureWithIndex(0)
+	require.NoError(t, err)
+	forged = &Disclosure{Value: append([]byte("Foo"), disclosure.Salt[0]), Salt: disclosure.Salt[1:], Proof: disclosure.Proof}
+	_, err = forged.Verify(tree.Root(), LayerOf(tree))
+	require.EqualError(t, err, "salt has incorrect length")
+}
@@ -31,16 +31,19 @@
 // # Implementation notes
 //
 // The tree pads its values to the next highest power of 2; values not supplied are treated as null with a value hash of 0.  This
-// can be seen graphically by generating a DOT representation of the graph with DOT().
+// can be seen graphically by generating a DOT representation of the graph with DOT().  Other padding strategies, including an
+// unbalanced tree without padding, can be selected with WithPadding().
 //
-// If salting is enabled it appends an 4-byte value to each piece of data.  The value is the binary representation of the index in
-// big-endian form.  Note that if there are more than 2^32 values in the tree the salt will wrap, being modulo 2^32
+// Trees are binary by default.  WithArity() creates trees in which each branch has 4, 8 or 16 children, reducing the depth of the
+// tree at the cost of larger proofs; each level of a proof then contains all of the siblings of the node at that level.
+//
+// If salting is enabled it appends a value generated from the index to each piece of data.  By default this is the 4-byte
+// big-endian representation of the index, which wraps if there are more than 2^32 values in the tree; trees that may grow beyond
+// this should use WithSaltFunc(IndexSalt64) for an 8-byte salt.
 package merkletree
 
 import (
-	"encoding/binary"
 	"fmt"
-	"math"
 	"strings"
 )
 
@@ -63,15 +66,22 @@ func (t *MerkleTree) DOTProof(proof *Proof, lf Formatter, bf Formatter) string {
 	rootIndices := make(map[uint64]int)
 
 	if proof != nil {
-		index := proof.Index + uint64(math.Ceil(float64(len(t.Nodes))/2))
+		arity := t.arity()
+		index := proof.Index + t.leafOffset()
 		valueIndices[proof.Index] = 1
 
-		for range proof.Hashes {
-			proofIndices[index^1] = 1
-			index /= 2
+		levels := len(proof.Hashes) / int(arity-1)
+		for i := 0; i < levels; i++ {
+			first := firstChild(parentOf(index, arity), arity)
+			for j := first; j < first+arity; j++ {
+				if j != index {
+					proofIndices[j] = 1
+				}
+			}
+			index = parentOf(index, arity)
 		}
 
-		numRootNodes := uint64(math.Exp2(math.Ceil(math.Log2(float64(len(t.Data))))-float64(len(proof.Hashes))+1)) - 1
+		numRootNodes := levelStart(depthFor(t.values(), arity)-levels+1, arity) - 1
 		for i := uint64(1); i <= numRootNodes; i++ {
 			rootIndices[i] = 1
 		}
@@ -116,24 +126,35 @@ func (t *MerkleTree) dot(rootIndices, valueIndices, proofIndices map[uint64]int,
 	builder.WriteString("digraph MerkleTree {")
 	builder.WriteString("rankdir = TB;")
 	builder.WriteString("node [shape=rectangle margin=\"0.2,0.2\"];")
-	dataLen := len(t.Data)
-	valuesOffset := int(math.Ceil(float64(len(t.Nodes)) / 2))
+	dataLen := int(t.values())
+	valuesOffset := int(t.leafOffset())
 	var nodeBuilder strings.Builder
 	nodeBuilder.WriteString("{rank=same")
-	for i := 0; i < valuesOffset; i++ {
+	for i := 0; i < len(t.Nodes)-valuesOffset; i++ {
+		if t.Nodes[valuesOffset+i] == nil {
+			// Unbalanced trees do not have padding.
+			continue
+		}
 		if i < dataLen {
 			t.dotLeaf(&builder, &nodeBuilder, i, valuesOffset+i, lf, bf, valueIndices, rootIndices, proofIndices)
 		} else {
 			t.dotEmptyLeaf(&builder, &nodeBuilder, valuesOffset+i, bf, rootIndices, proofIndices)
 		}
 		if dataLen > 1 {
-			builder.WriteString(fmt.Sprintf("%d->%d;", valuesOffset+i, (valuesOffset+i)/2))
+			builder.WriteString(fmt.Sprintf("%d->%d;", valuesOffset+i, parentOf(uint64(valuesOffset+i), t.arity())))
 		}
 	}
 	nodeBuilder.WriteString("};")
 	builder.WriteString(nodeBuilder.String())
 
 	t.dotBranches(rootIndices, proofIndices, bf, &builder)
+	if t.CountMixIn {
+		builder.WriteString(fmt.Sprintf("0 [label=\"%s\"", bf.Format(t.Nodes[0])))
+		if rootIndices[1] > 0 {
+			builder.WriteString(" style=filled fillcolor=\"#8080ff\"")
+		}
+		builder.WriteString(fmt.Sprintf("];1->0 [label=\"+%d\"];", t.values()))
+	}
 
 	builder.WriteString("}")
 
@@ -142,8 +163,11 @@ func (t *MerkleTree) dot(rootIndices, valueIndices, proofIndices map[uint64]int,
 
 //nolint:revive
 func (t *MerkleTree) dotBranches(rootIndices, proofIndices map[uint64]int, bf Formatter, builder *strings.Builder) {
-	valuesOffset := int(math.Ceil(float64(len(t.Nodes)) / 2))
+	valuesOffset := int(t.leafOffset())
 	for valueIndex := valuesOffset - 1; valueIndex > 0; valueIndex-- {
+		if t.Nodes[valueIndex] == nil {
+			continue
+		}
 		builder.WriteString(fmt.Sprintf("%d [label=\"%s\"", valueIndex, bf.Format(t.Nodes[valueIndex])))
 		if rootIndices[uint64(valueIndex)] > 0 {
 			builder.WriteString(" style=filled fillcolor=\"#8080ff\"")
@@ -152,7 +176,7 @@ func (t *MerkleTree) dotBranches(rootIndices, proofIndices map[uint64]int, bf Fo
 		}
 		builder.WriteString("];")
 		if valueIndex > 1 {
-			builder.WriteString(fmt.Sprintf("%d->%d;", valueIndex, valueIndex/2))
+			builder.WriteString(fmt.Sprintf("%d->%d;", valueIndex, parentOf(uint64(valueIndex), t.arity())))
 		}
 	}
 }
@@ -168,24 +192,27 @@ func (t *MerkleTree) dotLeaf(builder *strings.Builder,
 	rootIndices map[uint64]int,
 	proofIndices map[uint64]int,
 ) {
-	builder.WriteString(fmt.Sprintf("\"%s\" [shape=oval", leafFormatter.Format(t.Data[i])))
-	if valueIndices[uint64(i)] > 0 {
-		builder.WriteString(" style=filled fillcolor=\"#ff4040\"")
-	}
-	builder.WriteString("];")
+	// Trees created from leaf hashes do not have values, so highlight the leaf itself.
+	if t.Data != nil {
+		builder.WriteString(fmt.Sprintf("\"%s\" [shape=oval", leafFormatter.Format(t.Data[i])))
+		if valueIndices[uint64(i)] > 0 {
+			builder.WriteString(" style=filled fillcolor=\"#ff4040\"")
+		}
+		builder.WriteString("];")
 
-	// Hash of the value
-	if t.Salt {
-		indexSalt := make([]byte, 4)
-		binary.BigEndian.PutUint32(indexSalt, uint32(i))
-		builder.WriteString(fmt.Sprintf("\"%s\"->%d [label=\"+%0x\"];", leafFormatter.Format(t.Data[i]), offset, indexSalt))
-	} else {
-		builder.WriteString(fmt.Sprintf("\"%s\"->%d;", leafFormatter.Format(t.Data[i]), offset))
+		// Hash of the value
+		if salt := t.leafSalt(uint64(i)); salt != nil {
+			builder.WriteString(fmt.Sprintf("\"%s\"->%d [label=\"+%0x\"];", leafFormatter.Format(t.Data[i]), offset, salt))
+		} else {
+			builder.WriteString(fmt.Sprintf("\"%s\"->%d;", leafFormatter.Format(t.Data[i]), offset))
+		}
 	}
 
 	nodeBuilder.WriteString(fmt.Sprintf(";%d", offset))
 	builder.WriteString(fmt.Sprintf("%d [label=\"%s\"", offset, branchFormatter.Format(t.Nodes[offset])))
-	if proofIndices[uint64(offset)] > 0 {
+	if t.Data == nil && valueIndices[uint64(i)] > 0 {
+		builder.WriteString(" style=filled fillcolor=\"#ff4040\"")
+	} else if proofIndices[uint64(offset)] > 0 {
 		builder.WriteString(" style=filled fillcolor=\"#00ff00\"")
 	} else if rootIndices[uint64(offset)] > 0 {
 		builder.WriteString(" style=filled fillcolor=\"#8080ff\"")
@@ -204,9 +231,7 @@ func (t *MerkleTree) dotEmptyLeaf(builder *strings.Builder,
 	rootIndices map[uint64]int,
 	proofIndices map[uint64]int,
 ) {
-	empty := make([]byte, len(t.Nodes[1]))
-
-	builder.WriteString(fmt.Sprintf("%d [label=\"%s\"", offset, branchFormatter.Format(empty)))
+	builder.WriteString(fmt.Sprintf("%d [label=\"%s\"", offset, branchFormatter.Format(t.Nodes[offset])))
 	if proofIndices[uint64(offset)] > 0 {
 		builder.WriteString(" style=filled fillcolor=\"#00ff00\"")
 	} else if rootIndices[uint64(offset)] > 0 {
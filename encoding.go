@@ -2,10 +2,14 @@ package merkletree
 
 import (
 	"encoding/json"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/blake3"
 	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/mimc"
 	"github.com/wealdtech/go-merkletree/v2/poseidon"
 	"github.com/wealdtech/go-merkletree/v2/sha3"
 )
@@ -16,9 +20,11 @@ func (t *MerkleTree) MarshalJSON() ([]byte, error) {
 
 	data, err := json.Marshal(&struct {
 		HashType string `json:"hash_type"`
+		SaltFunc string `json:"salt_func,omitempty"`
 		*ExportTree
 	}{
 		HashType:   t.Hash.HashName(),
+		SaltFunc:   saltFuncName(t.saltFunc()),
 		ExportTree: (*ExportTree)(t),
 	})
 	if err != nil {
@@ -33,6 +39,7 @@ func (t *MerkleTree) UnmarshalJSON(data []byte) error {
 	type ExportTree MerkleTree
 	aux := &struct {
 		HashType string `json:"hash_type"`
+		SaltFunc string `json:"salt_func"`
 		*ExportTree
 	}{
 		ExportTree: (*ExportTree)(t),
@@ -41,20 +48,73 @@ func (t *MerkleTree) UnmarshalJSON(data []byte) error {
 		return errors.Wrap(err, "failed to unmarshal JSON")
 	}
 
-	switch aux.HashType {
+	hash, err := hashTypeFromName(aux.HashType)
+	if err != nil {
+		return err
+	}
+	aux.Hash = hash
+
+	// Trees without a salt function were created with the 4-byte index salt.
+	if aux.Salt {
+		if aux.SaltFunc == "" {
+			aux.SaltFunc = "index32"
+		}
+		saltFunc, err := saltFuncFromName(aux.SaltFunc)
+		if err != nil {
+			return err
+		}
+		aux.ExportTree.SaltFunc = saltFunc
+	}
+
+	var originalIndices []uint64
+	if aux.Positions != nil {
+		originalIndices, err = invertPositions(aux.Positions)
+		if err != nil {
+			return err
+		}
+	}
+	t.originalIndices = originalIndices
+
+	return nil
+}
+
+// hashTypeFromName returns the hash type for the given hash name.
+// Hash types that require secret information, such as keyed hashes, cannot be obtained from their name and return an error.
+func hashTypeFromName(name string) (HashType, error) {
+	switch name {
 	case "sha512":
-		aux.Hash = sha3.New512()
+		return sha3.New512(), nil
 	case "sha256":
-		aux.Hash = sha3.New256()
+		return sha3.New256(), nil
 	case "blake2b":
-		aux.Hash = blake2b.New()
+		return blake2b.New(), nil
+	case "blake3":
+		return blake3.New(), nil
 	case "keccak256":
-		aux.Hash = keccak256.New()
+		return keccak256.New(), nil
+	case "mimc7":
+		return mimc.New(), nil
 	case "poseidon":
-		aux.Hash = poseidon.New()
-	default:
-		return errors.New("cannot parse hash type")
+		return poseidon.New(), nil
+	case "poseidon-field":
+		return poseidon.NewFieldElements(), nil
 	}
 
-	return nil
+	if context, isDeriveKey := strings.CutPrefix(name, "blake3-derive-key:"); isDeriveKey {
+		return blake3.NewDeriveKey(context), nil
+	}
+	if name == "blake3-keyed" || strings.HasPrefix(name, "blake2b-keyed") {
+		return nil, errors.New("keyed hash types cannot be decoded without their key")
+	}
+
+	if strings.HasPrefix(name, "blake2b-") {
+		bits, err := strconv.Atoi(strings.TrimPrefix(name, "blake2b-"))
+		if err == nil && bits%8 == 0 {
+			if hash, err := blake2b.NewWithLength(bits / 8); err == nil {
+				return hash, nil
+			}
+		}
+	}
+
+	return nil, errors.New("cannot parse hash type")
 }
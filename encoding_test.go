@@ -1,10 +1,13 @@
 package merkletree
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/blake3"
 	"github.com/wealdtech/go-merkletree/v2/sha3"
 )
 
@@ -31,3 +34,130 @@ func TestEncoding(t *testing.T) {
 
 	require.Equal(t, tree.Root(), newTree.Root())
 }
+
+func TestEncodingBLAKE2bLength(t *testing.T) {
+	hashType, err := blake2b.NewWithLength(20)
+	require.NoError(t, err)
+
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithHashType(hashType),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	require.Equal(t, "blake2b-160", newTree.Hash.HashName())
+	require.Equal(t, tree.Root(), newTree.Root())
+}
+
+func TestEncodingKeyed(t *testing.T) {
+	hashType, err := blake2b.NewKeyed([]byte("secret"), 32)
+	require.NoError(t, err)
+
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithHashType(hashType),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	var newTree MerkleTree
+	require.EqualError(t, json.Unmarshal(exported, &newTree), "keyed hash types cannot be decoded without their key")
+
+	keyedHashType, err := blake3.NewKeyed(bytes.Repeat([]byte{0x01}, 32))
+	require.NoError(t, err)
+	tree, err = NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithHashType(keyedHashType),
+	)
+	require.NoError(t, err)
+	exported, err = json.Marshal(tree)
+	require.NoError(t, err)
+	require.EqualError(t, json.Unmarshal(exported, &newTree), "keyed hash types cannot be decoded without their key")
+}
+
+func TestEncodingBLAKE3DeriveKey(t *testing.T) {
+	trees := make([]*MerkleTree, 2)
+	for i, context := range []string{"example.com 2024 tree one", "example.com 2024 tree two"} {
+		tree, err := NewTree(
+			WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+			WithHashType(blake3.NewDeriveKey(context)),
+		)
+		require.NoError(t, err)
+
+		exported, err := json.Marshal(tree)
+		require.NoError(t, err)
+
+		var newTree MerkleTree
+		require.NoError(t, json.Unmarshal(exported, &newTree))
+		require.Equal(t, "blake3-derive-key:"+context, newTree.Hash.HashName())
+		require.Equal(t, tree.Root(), newTree.Root())
+		trees[i] = tree
+	}
+
+	// Trees with different contexts have different hash types.
+	require.NotEqual(t, trees[0].Hash.HashName(), trees[1].Hash.HashName())
+}
+
+func TestEncodingSaltFunc(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithSaltFunc(IndexSalt64),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+	require.Contains(t, string(exported), `"salt_func":"index64"`)
+
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	require.True(t, newTree.Salt)
+	require.Equal(t, "index64", saltFuncName(newTree.SaltFunc))
+	require.Equal(t, tree.Root(), newTree.Root())
+	require.Equal(t, tree.DOT(nil, nil), newTree.DOT(nil, nil))
+}
+
+func TestEncodingLegacySalt(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithSalt(true),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	// Remove the salt function to emulate a tree exported before salt functions were available.
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(exported, &fields))
+	require.Equal(t, `"index32"`, string(fields["salt_func"]))
+	delete(fields, "salt_func")
+	exported, err = json.Marshal(fields)
+	require.NoError(t, err)
+
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	require.Equal(t, "index32", saltFuncName(newTree.SaltFunc))
+	require.Equal(t, tree.Root(), newTree.Root())
+}
+
+func TestEncodingCustomSaltFunc(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		WithSaltFunc(func(index uint64) []byte { return []byte{byte(index)} }),
+	)
+	require.NoError(t, err)
+
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+
+	var newTree MerkleTree
+	require.EqualError(t, json.Unmarshal(exported, &newTree), "cannot parse salt function")
+}
@@ -0,0 +1,216 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Forest is a tree of trees, in which the roots of child trees are the data of a parent tree.
+// Each child, and the parent, can have its own hash type, salt and sorted setting.
+type Forest struct {
+	// Parent is the tree of the roots of the children.
+	Parent *MerkleTree
+	// Children are the child trees, in the order of their roots in the data of the parent.
+	Children []*MerkleTree
+}
+
+// Layer is the configuration of a tree required to verify proofs against it, such as a layer of a forest.
+type Layer struct {
+	// Hash is the hash type of the layer.
+	Hash HashType
+	// SaltFunc generates the salt for each value if the layer is salted by index, otherwise nil.
+	SaltFunc SaltFunc
+	// Salts is true if the layer has per-value salts.
+	Salts bool
+	// Sorted is true if the layer is sorted.
+	Sorted bool
+}
+
+// CompositeProof is a proof of a value in a child tree of a forest against the root of the forest.
+type CompositeProof struct {
+	// Child is the proof of the value in the child tree.
+	Child *Proof
+	// ChildSalt is the salt of the value if the child tree has per-value salts, or is sorted and salted by index.
+	ChildSalt []byte `json:",omitempty"`
+	// Parent is the proof of the root of the child tree in the parent tree.
+	Parent *Proof
+	// ParentSalt is the salt of the root of the child tree if the parent tree has per-value salts, or is sorted and salted by
+	// index.
+	ParentSalt []byte `json:",omitempty"`
+}
+
+// NewForest creates a new forest from the child trees.  The parameters are those of the parent tree, and must not include data.
+func NewForest(children []*MerkleTree, params ...Parameter) (*Forest, error) {
+	if len(children) == 0 {
+		return nil, errors.New("forest must have at least 1 child")
+	}
+	roots := make([][]byte, len(children))
+	for i := range children {
+		if children[i] == nil {
+			return nil, fmt.Errorf("missing child at index %d", i)
+		}
+		roots[i] = children[i].Root()
+	}
+
+	parameters := parameters{}
+	for _, p := range params {
+		p.apply(&parameters)
+	}
+	if parameters.data != nil || parameters.dataFunc != nil || parameters.leafHashes != nil {
+		return nil, errors.New("forest does not use the data parameter")
+	}
+
+	parent, err := NewTree(append(params, WithData(roots))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create parent tree")
+	}
+
+	return &Forest{
+		Parent:   parent,
+		Children: children,
+	}, nil
+}
+
+// Root returns the root of the forest, which is the root of the parent tree.
+func (f *Forest) Root() []byte {
+	return f.Parent.Root()
+}
+
+// GenerateProof generates a composite proof for a piece of data in the given child.
+// If the data is not present in the child, or is present more than once, this will return an error.
+func (f *Forest) GenerateProof(child uint64, data []byte) (*CompositeProof, error) {
+	if child >= uint64(len(f.Children)) {
+		return nil, errors.New("child out of range")
+	}
+	index, err := f.Children[child].indexOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.GenerateProofWithIndex(child, index)
+}
+
+// GenerateProofWithIndex generates a composite proof for the data at the given index in the tree of the given child.
+// The child is the index of the child as supplied to NewForest(), regardless of whether or not the parent tree is sorted.
+func (f *Forest) GenerateProofWithIndex(child uint64, index uint64) (*CompositeProof, error) {
+	if child >= uint64(len(f.Children)) {
+		return nil, errors.New("child out of range")
+	}
+	childTree := f.Children[child]
+	childProof, err := childTree.generateProof(index, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate child proof")
+	}
+
+	parentIndex, err := f.Parent.TreeIndex(child)
+	if err != nil {
+		return nil, err
+	}
+	parentProof, err := f.Parent.generateProof(parentIndex, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate parent proof")
+	}
+
+	proof := &CompositeProof{
+		Child:  childProof,
+		Parent: parentProof,
+	}
+	// Values of sorted trees are salted by their original index, which cannot be obtained from the proof.
+	if childTree.Salts != nil || (childTree.Salt && childTree.Sorted) {
+		proof.ChildSalt = childTree.leafSalt(index)
+	}
+	if f.Parent.Salts != nil || (f.Parent.Salt && f.Parent.Sorted) {
+		proof.ParentSalt = f.Parent.leafSalt(parentIndex)
+	}
+
+	return proof, nil
+}
+
+// LayerOf returns the layer configuration of the tree, for verification of composite proofs.
+func LayerOf(tree *MerkleTree) *Layer {
+	return &Layer{
+		Hash:     tree.Hash,
+		SaltFunc: tree.saltFunc(),
+		Salts:    tree.Salts != nil,
+		Sorted:   tree.Sorted,
+	}
+}
+
+// leafSalt returns the salt of the value at the given index in the layer, where salt is that supplied with the proof.
+// The supplied salt is only used if it cannot be generated from the index, and must be of the length that the layer generates,
+// as otherwise bytes could be moved between the value and its salt.
+func (l *Layer) leafSalt(index uint64, salt []byte) ([]byte, error) {
+	switch {
+	case l.Salts:
+		if len(salt) != _randomSaltLength {
+			return nil, errors.New("salt has incorrect length")
+		}
+
+		return salt, nil
+	case l.SaltFunc != nil && l.Sorted:
+		// Values of sorted layers are salted by their original index, which cannot be obtained from the proof.
+		if len(salt) != len(l.SaltFunc(0)) {
+			return nil, errors.New("salt has incorrect length")
+		}
+
+		return salt, nil
+	default:
+		return saltFor(l.SaltFunc, index), nil
+	}
+}
+
+// Verify verifies the composite proof for a piece of data against the root of a forest, with the configuration of the child
+// and parent layers.  The salts of the proof are only used for layers with per-value salts, or that are sorted and salted by
+// index; otherwise they are generated from the indices of the proofs.
+//
+// This returns true if the proof is verified, otherwise false.
+func (p *CompositeProof) Verify(data []byte, root []byte, child *Layer, parent *Layer) (bool, error) {
+	if p.Child == nil || p.Parent == nil {
+		return false, errors.New("no proof specified")
+	}
+	if child == nil || parent == nil || child.Hash == nil || parent.Hash == nil {
+		return false, errors.New("no layer specified")
+	}
+	childSalt, err := child.leafSalt(p.Child.Index, p.ChildSalt)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid child salt")
+	}
+	if err := checkLeaf(child.Hash, data, childSalt); err != nil {
+		return false, err
+	}
+	if err := checkHashes(child.Hash, p.Child.Hashes); err != nil {
+		return false, errors.Wrap(err, "invalid child proof")
+	}
+	parentSalt, err := parent.leafSalt(p.Parent.Index, p.ParentSalt)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid parent salt")
+	}
+	if err := checkHashes(parent.Hash, p.Parent.Hashes); err != nil {
+		return false, errors.Wrap(err, "invalid parent proof")
+	}
+	if !p.Child.valid() || !p.Parent.valid() {
+		return false, nil
+	}
+
+	childRoot := generateProofHash(data, childSalt, p.Child, child.Sorted, child.Hash)
+	if err := checkLeaf(parent.Hash, childRoot, parentSalt); err != nil {
+		return false, errors.Wrap(err, "invalid child root")
+	}
+
+	return bytes.Equal(generateProofHash(childRoot, parentSalt, p.Parent, parent.Sorted, parent.Hash), root), nil
+}
@@ -0,0 +1,161 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+)
+
+func forestChildData(child int, values int) [][]byte {
+	data := make([][]byte, values)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("tenant %d value %d", child, i))
+	}
+
+	return data
+}
+
+func TestForest(t *testing.T) {
+	tests := []struct {
+		childParams  []Parameter
+		parentParams []Parameter
+	}{
+		{},
+		{
+			childParams:  []Parameter{WithHashType(keccak256.New())},
+			parentParams: []Parameter{WithHashType(sha3.New256())},
+		},
+		{
+			childParams:  []Parameter{WithHashType(blake2b.New()), WithSalt(true)},
+			parentParams: []Parameter{WithSorted(true)},
+		},
+		{
+			childParams:  []Parameter{WithSorted(true), WithCountMixIn(true)},
+			parentParams: []Parameter{WithHashType(keccak256.New()), WithSalt(true), WithArity(4)},
+		},
+		{
+			childParams:  []Parameter{WithSalt(true), WithSorted(true)},
+			parentParams: []Parameter{WithSalt(true), WithSorted(true), WithArity(4)},
+		},
+		{
+			childParams:  []Parameter{WithRandomSalts(true)},
+			parentParams: []Parameter{WithRandomSalts(true), WithPadding(PaddingNone)},
+		},
+	}
+
+	for i, test := range tests {
+		children := make([]*MerkleTree, 5)
+		for j := range children {
+			child, err := NewTree(append([]Parameter{WithData(forestChildData(j, j+3))}, test.childParams...)...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			children[j] = child
+		}
+		forest, err := NewForest(children, test.parentParams...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, forest.Parent.Root(), forest.Root(), fmt.Sprintf("failed at test %d", i))
+
+		parentLayer := LayerOf(forest.Parent)
+		for j, child := range children {
+			childLayer := LayerOf(child)
+			for _, data := range forestChildData(j, j+3) {
+				proof, err := forest.GenerateProof(uint64(j), data)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				verified, err := proof.Verify(data, forest.Root(), childLayer, parentLayer)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.True(t, verified, fmt.Sprintf("failed at test %d child %d", i, j))
+
+				// The proof must not verify for another tenant's data.
+				verified, err = proof.Verify(forestChildData(j+1, 1)[0], forest.Root(), childLayer, parentLayer)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.False(t, verified, fmt.Sprintf("failed at test %d child %d", i, j))
+			}
+		}
+	}
+}
+
+func TestForestErrors(t *testing.T) {
+	child, err := NewTree(WithData(forestChildData(0, 4)))
+	require.NoError(t, err)
+
+	_, err = NewForest(nil)
+	assert.EqualError(t, err, "forest must have at least 1 child")
+	_, err = NewForest([]*MerkleTree{child, nil})
+	assert.EqualError(t, err, "missing child at index 1")
+	_, err = NewForest([]*MerkleTree{child}, WithData(forestChildData(0, 4)))
+	assert.EqualError(t, err, "forest does not use the data parameter")
+
+	forest, err := NewForest([]*MerkleTree{child})
+	require.NoError(t, err)
+	_, err = forest.GenerateProof(1, forestChildData(0, 1)[0])
+	assert.EqualError(t, err, "child out of range")
+	_, err = forest.GenerateProof(0, []byte("missing"))
+	assert.EqualError(t, err, "data not found")
+	_, err = forest.GenerateProofWithIndex(1, 0)
+	assert.EqualError(t, err, "child out of range")
+	_, err = forest.GenerateProofWithIndex(0, 4)
+	assert.EqualError(t, err, "failed to generate child proof: index out of range")
+
+	proof, err := forest.GenerateProofWithIndex(0, 0)
+	require.NoError(t, err)
+	layer := LayerOf(child)
+	_, err = (&CompositeProof{}).Verify(nil, forest.Root(), layer, layer)
+	assert.EqualError(t, err, "no proof specified")
+	_, err = proof.Verify(nil, forest.Root(), layer, nil)
+	assert.EqualError(t, err, "no layer specified")
+
+	// A proof for a layer with different settings does not verify.
+	verified, err := proof.Verify(forestChildData(0, 1)[0], forest.Root(), layer, &Layer{Hash: keccak256.New()})
+	require.NoError(t, err)
+	assert.False(t, verified)
+}
+
+func TestForestForgery(t *testing.T) {
+	child, err := NewTree(WithData([][]byte{[]byte("Foo"), []byte("Bar")}), WithSalt(true))
+	require.NoError(t, err)
+	other, err := NewTree(WithData([][]byte{[]byte("Baz")}), WithRandomSalts(true))
+	require.NoError(t, err)
+	forest, err := NewForest([]*MerkleTree{child, other}, WithSalt(true), WithSorted(true))
+	require.NoError(t, err)
+	parentLayer := LayerOf(forest.Parent)
+
+	// Salts supplied with the proof are ignored for index-salted layers.
+	proof, err := forest.GenerateProofWithIndex(0, 0)
+	require.NoError(t, err)
+	proof.ChildSalt = []byte{0x00, 0x00, 0x00}
+	verified, err := proof.Verify([]byte("Foo\x00"), forest.Root(), LayerOf(child), parentLayer)
+	require.NoError(t, err)
+	assert.False(t, verified)
+	verified, err = proof.Verify([]byte("Foo"), forest.Root(), LayerOf(child), parentLayer)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// Salts supplied with the proof must be the length generated by the layer.
+	proof.ParentSalt = proof.ParentSalt[1:]
+	_, err = proof.Verify([]byte("Foo"), forest.Root(), LayerOf(child), parentLayer)
+	assert.EqualError(t, err, "invalid parent salt: salt has incorrect length")
+
+	proof, err = forest.GenerateProofWithIndex(1, 0)
+	require.NoError(t, err)
+	forged := append([]byte("Baz"), proof.ChildSalt[0])
+	proof.ChildSalt = proof.ChildSalt[1:]
+	_, err = proof.Verify(forged, forest.Root(), LayerOf(other), parentLayer)
+	assert.EqualError(t, err, "invalid child salt: salt has incorrect length")
+}
@@ -13,6 +13,12 @@
 
 package merkletree
 
+import (
+	"fmt"
+	"hash"
+	"math/big"
+)
+
 // HashFunc is a hashing function.
 type HashFunc func(...[]byte) []byte
 
@@ -27,3 +33,185 @@ type HashType interface {
 	// HashLength provides the length of the hash.
 	HashLength() int
 }
+
+// StreamingHashType defines an optional interface that can be supplied by hash functions to avoid allocating memory for each hash.
+// If a hash type supplies this interface it will be used in preference to Hash() when building trees and verifying proofs.
+type StreamingHashType interface {
+	HashType
+
+	// NewHasher returns a new hasher.  The hasher will be reset, written to and summed in to a caller-supplied buffer, so must
+	// generate the same result as Hash() for the same input.
+	NewHasher() hash.Hash
+}
+
+// BatchHashType defines an optional interface that can be supplied by hash functions to hash many pairs of values at once.
+// If a hash type supplies this interface it will be used to hash each level of branches when building trees, allowing the
+// implementation to vectorize or parallelize the work.
+type BatchHashType interface {
+	HashType
+
+	// HashPairs hashes the concatenation of each lefts[i] and rights[i], placing the result in dst[i].  If dst[i] has sufficient
+	// capacity the result may be written in to its existing storage.  The result for each pair must be the same as that of
+	// Hash(lefts[i], rights[i]).
+	HashPairs(dst [][]byte, lefts [][]byte, rights [][]byte)
+}
+
+// CheckedHashType defines an optional interface that can be supplied by hash functions that only accept some values as data, for
+// example hash functions that operate on field elements.  If a hash type supplies this interface trees will not be created, and
+// proofs will not be verified, for data, salts or hashes that fail the check.
+type CheckedHashType interface {
+	HashType
+
+	// CheckData returns an error if the data cannot be used as an input to the hash.
+	CheckData(data []byte) error
+}
+
+// FieldElementHashType defines an optional interface that can be supplied by hash functions that operate on field elements, such
+// as those used in zero-knowledge circuits.
+type FieldElementHashType interface {
+	HashType
+
+	// FieldElement returns the field element represented by the data.
+	FieldElement(data []byte) (*big.Int, error)
+}
+
+// checkData checks that the data can be hashed by the given hash type.
+func checkData(hashType HashType, data []byte) error {
+	checkedHashType, isChecked := hashType.(CheckedHashType)
+	if !isChecked {
+		return nil
+	}
+
+	return checkedHashType.CheckData(data)
+}
+
+// checkLeaf checks that the data and its salt, which can be nil if unsalted, can be hashed by the given hash type to form a leaf.
+func checkLeaf(hashType HashType, data []byte, salt []byte) error {
+	if err := checkData(hashType, data); err != nil {
+		return err
+	}
+	if salt == nil {
+		return nil
+	}
+	if err := checkData(hashType, salt); err != nil {
+		return fmt.Errorf("invalid salt: %w", err)
+	}
+
+	return nil
+}
+
+// checkHashes checks that the hashes can be hashed by the given hash type.  Hashes that are nil, as per the missing siblings
+// of unbalanced trees, are ignored.
+func checkHashes(hashType HashType, hashes [][]byte) error {
+	if _, isChecked := hashType.(CheckedHashType); !isChecked {
+		return nil
+	}
+	for i := range hashes {
+		if hashes[i] == nil {
+			continue
+		}
+		if err := checkData(hashType, hashes[i]); err != nil {
+			return fmt.Errorf("invalid hash at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// hasher hashes leaves and branches, streaming the data if the hash type allows it.
+// A hasher is not safe for concurrent use.
+type hasher struct {
+	hashType HashType
+	stream   hash.Hash
+	batch    BatchHashType
+	// fieldElements is true if the hash type treats its inputs as field elements.
+	fieldElements bool
+}
+
+// newHasher creates a new hasher for the given hash type.
+func newHasher(hashType HashType) *hasher {
+	h := &hasher{
+		hashType: hashType,
+	}
+	if streamingHashType, isStreaming := hashType.(StreamingHashType); isStreaming {
+		h.stream = streamingHashType.NewHasher()
+	}
+	if batchHashType, isBatch := hashType.(BatchHashType); isBatch {
+		h.batch = batchHashType
+	}
+	if fieldElementHashType, isFieldElement := hashType.(FieldElementHashType); isFieldElement {
+		// Hash types can have modes that do not operate on field elements, in which case they cannot provide one.
+		_, err := fieldElementHashType.FieldElement([]byte{})
+		h.fieldElements = err == nil
+	}
+
+	return h
+}
+
+// streaming returns true if the hasher writes hashes in to the supplied buffers.
+func (h *hasher) streaming() bool {
+	return h.stream != nil
+}
+
+// hashLeaf hashes data with an optional salt.
+// If the hasher is streaming the hash is written to dst, re-using its capacity.
+func (h *hasher) hashLeaf(dst []byte, data []byte, salt []byte) []byte {
+	if h.stream == nil {
+		if salt == nil {
+			return h.hashType.Hash(data)
+		}
+
+		return h.hashType.Hash(data, salt)
+	}
+
+	h.stream.Reset()
+	_, _ = h.stream.Write(data)
+	if salt != nil {
+		_, _ = h.stream.Write(salt)
+	}
+
+	return h.stream.Sum(dst[:0])
+}
+
+// hashBranch hashes a pair of child nodes.
+// If the hasher is streaming the hash is written to dst, re-using its capacity.  dst can be the same as either child.
+func (h *hasher) hashBranch(dst []byte, left []byte, right []byte) []byte {
+	if h.stream == nil {
+		return h.hashType.Hash(left, right)
+	}
+
+	h.stream.Reset()
+	_, _ = h.stream.Write(left)
+	_, _ = h.stream.Write(right)
+
+	return h.stream.Sum(dst[:0])
+}
+
+// hashBranches hashes multiple pairs of child nodes, placing the results in dst.
+// If the hasher is streaming the hashes are written in to the existing storage of dst.
+func (h *hasher) hashBranches(dst [][]byte, lefts [][]byte, rights [][]byte) {
+	if h.batch != nil {
+		h.batch.HashPairs(dst, lefts, rights)
+
+		return
+	}
+
+	for i := range dst {
+		dst[i] = h.hashBranch(dst[i], lefts[i], rights[i])
+	}
+}
+
+// hashChildren hashes any number of child nodes, as used by trees with an arity greater than 2.
+// If the hasher is streaming the hash is written to dst, re-using its capacity.  dst can be the same as any child.
+func (h *hasher) hashChildren(dst []byte, children [][]byte) []byte {
+	if h.stream == nil {
+		return h.hashType.Hash(children...)
+	}
+
+	h.stream.Reset()
+	for i := range children {
+		_, _ = h.stream.Write(children[i])
+	}
+
+	return h.stream.Sum(dst[:0])
+}
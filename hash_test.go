@@ -0,0 +1,215 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/blake3"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/mimc"
+	"github.com/wealdtech/go-merkletree/v2/poseidon"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+)
+
+// nonStreamingHashType hides the streaming interface of a hash type.
+type nonStreamingHashType struct {
+	HashType
+}
+
+func TestStreaming(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+
+	hashTypes := []HashType{
+		blake2b.New(),
+		blake3.New(),
+		keccak256.New(),
+		sha3.New256(),
+		sha3.New512(),
+	}
+
+	for _, hashType := range hashTypes {
+		require.Implements(t, (*StreamingHashType)(nil), hashType)
+		for _, salt := range []bool{false, true} {
+			for _, sorted := range []bool{false, true} {
+				if salt && sorted {
+					// Salts are applied before sorting so do not match the sorted indices.
+					continue
+				}
+				name := fmt.Sprintf("%s/salt=%t/sorted=%t", hashType.HashName(), salt, sorted)
+				tree, err := NewTree(
+					WithData(append([][]byte{}, data...)),
+					WithHashType(hashType),
+					WithSalt(salt),
+					WithSorted(sorted),
+				)
+				require.NoError(t, err, name)
+				plainTree, err := NewTree(
+					WithData(append([][]byte{}, data...)),
+					WithHashType(&nonStreamingHashType{hashType}),
+					WithSalt(salt),
+					WithSorted(sorted),
+				)
+				require.NoError(t, err, name)
+				require.Equal(t, plainTree.Nodes, tree.Nodes, name)
+
+				if !sorted {
+					require.True(t, VerifyPollardUsing(tree.Pollard(2), hashType), name)
+					for i := range tree.Data {
+						proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+						require.NoError(t, err, name)
+						verified, err := VerifyProofUsing(tree.Data[i], salt, proof, [][]byte{tree.Root()}, hashType)
+						require.NoError(t, err, name)
+						require.True(t, verified, name)
+					}
+				}
+				multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{1, 4, 5, 12})
+				require.NoError(t, err, name)
+				verified, err := multiProof.Verify([][]byte{tree.Data[1], tree.Data[4], tree.Data[5], tree.Data[12]}, tree.Root())
+				require.NoError(t, err, name)
+				require.True(t, verified, name)
+			}
+		}
+	}
+}
+
+func BenchmarkNewTree(b *testing.B) {
+	data := make([][]byte, 65536)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+
+	for _, hashType := range []HashType{blake2b.New(), &nonStreamingHashType{blake2b.New()}} {
+		b.Run(fmt.Sprintf("%T", hashType), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := NewTree(
+					WithData(data),
+					WithHashType(hashType),
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	data := make([][]byte, 10000)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+
+	hashType := blake2b.New()
+	require.Implements(t, (*BatchHashType)(nil), hashType)
+	for _, sorted := range []bool{false, true} {
+		tree, err := NewTree(
+			WithData(append([][]byte{}, data...)),
+			WithHashType(hashType),
+			WithSorted(sorted),
+		)
+		require.NoError(t, err)
+		plainTree, err := NewTree(
+			WithData(append([][]byte{}, data...)),
+			WithHashType(&nonStreamingHashType{hashType}),
+			WithSorted(sorted),
+		)
+		require.NoError(t, err)
+		require.Equal(t, plainTree.Nodes, tree.Nodes)
+	}
+}
+
+// fieldElementHashType is a hash type that operates on field elements.
+type fieldElementHashType interface {
+	HashType
+	HashFieldElements(data ...[]byte) ([]byte, error)
+}
+
+func TestCheckedHashType(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}}
+	invalid := _byteArray("30644e72e131a029b85045b68181585d2833e84879b9709143e1f593f0000001")
+
+	for _, hashType := range []fieldElementHashType{poseidon.NewFieldElements(), mimc.New()} {
+		name := hashType.HashName()
+		tree, err := NewTree(
+			WithData(data),
+			WithHashType(hashType),
+		)
+		require.NoError(t, err, name)
+		// Root is H(H(H(1), H(2)), H(H(3), 0)).
+		expected, err := hashType.HashFieldElements(
+			hashType.Hash(hashType.Hash([]byte{0x01}), hashType.Hash([]byte{0x02})),
+			hashType.Hash(hashType.Hash([]byte{0x03}), make([]byte, 32)),
+		)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, tree.Root(), name)
+
+		proof, err := tree.GenerateProofWithIndex(2, 0)
+		require.NoError(t, err, name)
+		verified, err := VerifyProofUsing(data[2], false, proof, [][]byte{tree.Root()}, hashType)
+		require.NoError(t, err, name)
+		require.True(t, verified, name)
+
+		_, err = VerifyProofUsing(invalid, false, proof, [][]byte{tree.Root()}, hashType)
+		require.EqualError(t, err, "value not inside field", name)
+
+		_, err = NewTree(
+			WithData([][]byte{{0x01}, invalid}),
+			WithHashType(hashType),
+		)
+		require.EqualError(t, err, "problem with parameters: invalid data at index 1: value not inside field", name)
+
+		_, err = NewTree(
+			WithLeafHashes([][]byte{make([]byte, 32), invalid}),
+			WithHashType(hashType),
+		)
+		require.EqualError(t, err, "problem with parameters: invalid leaf hash at index 1: value not inside field", name)
+
+		invalidSalt := func(uint64) []byte { return invalid }
+		_, err = NewTree(
+			WithData(data),
+			WithHashType(hashType),
+			WithSaltFunc(invalidSalt),
+		)
+		require.EqualError(t, err, "problem with parameters: invalid data at index 0: invalid salt: value not inside field", name)
+		_, err = VerifyProofUsingSaltFunc(data[2], invalidSalt, proof, [][]byte{tree.Root()}, hashType)
+		require.EqualError(t, err, "invalid salt: value not inside field", name)
+
+		invalidProof := &Proof{
+			Hashes: [][]byte{proof.Hashes[0], invalid},
+			Index:  proof.Index,
+		}
+		_, err = VerifyProofUsing(data[2], false, invalidProof, [][]byte{tree.Root()}, hashType)
+		require.EqualError(t, err, "invalid hash at index 1: value not inside field", name)
+		_, err = VerifyProofUsingLeafHash(tree.Nodes[6], invalidProof, [][]byte{tree.Root()}, hashType)
+		require.EqualError(t, err, "invalid hash at index 1: value not inside field", name)
+		_, err = VerifyProofUsingLeafHash(invalid, proof, [][]byte{tree.Root()}, hashType)
+		require.EqualError(t, err, "invalid leaf hash: value not inside field", name)
+
+		multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{2})
+		require.NoError(t, err, name)
+		for index := range multiProof.Hashes {
+			multiProof.Hashes[index] = invalid
+		}
+		_, err = multiProof.Verify([][]byte{data[2]}, tree.Root())
+		require.ErrorContains(t, err, "value not inside field", name)
+	}
+}
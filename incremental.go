@@ -0,0 +1,279 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// IncrementalTree is a binary Merkle tree of fixed depth to which values are appended, as used by the Ethereum deposit contract
+// and Zcash note commitments.  Only the frontier of the tree is stored, so its state is proportional to its depth regardless of
+// the number of values.
+//
+// The root is the same as that of a MerkleTree created from the same values with the same parameters and a width of 2^depth.
+// Proofs are obtained from witnesses, which are updated by their holders as values are appended to the tree and can be verified
+// with VerifyProofUsingSaltFunc().
+//
+// An incremental tree is not safe for concurrent use.
+type IncrementalTree struct {
+	config   *incrementalConfig
+	hasher   *hasher
+	frontier *frontier
+}
+
+// IncrementalWitness is the witness of a value in an incremental tree, from which its proof is obtained.  The witness is updated
+// with each value appended to the tree after its own value, so that its proof remains valid against the current root.
+//
+// An incremental witness is not safe for concurrent use.
+type IncrementalWitness struct {
+	config *incrementalConfig
+	hasher *hasher
+	index  uint64
+	count  uint64
+	// siblings are the siblings of the value at each level, or nil if they are right siblings that are not yet complete.
+	siblings [][]byte
+	// cursor is the frontier of the incomplete right sibling that is currently being filled, if any.
+	cursor      *frontier
+	cursorLevel int
+}
+
+// incrementalConfig is the configuration shared by an incremental tree and its witnesses.
+type incrementalConfig struct {
+	hash       HashType
+	saltFunc   SaltFunc
+	countMixIn bool
+	depth      int
+	// empties are the roots of subtrees of each height that contain only padding.
+	empties [][]byte
+}
+
+// frontier is the state of a binary tree of fixed depth that is filled from the left.  Each level holds the last left node,
+// which is awaiting its right sibling.
+type frontier struct {
+	depth int
+	count uint64
+	nodes [][]byte
+}
+
+// NewIncrementalTree creates a new, empty, incremental tree of the given depth.
+// Incremental trees can use PaddingZeroHash or PaddingEmptyHash, and cannot be sorted or have per-value salts.
+func NewIncrementalTree(depth int, params ...Parameter) (*IncrementalTree, error) {
+	parameters, err := parseAndCheckIncrementalParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+	if depth < 0 || depth > 63 {
+		return nil, errors.New("depth must be between 0 and 63")
+	}
+
+	hasher := newHasher(parameters.hash)
+	empties := make([][]byte, depth+1)
+	empties[0] = paddingLeaf(parameters.padding, parameters.hash, nil)
+	for i := 1; i <= depth; i++ {
+		empties[i] = hasher.hashBranch(nil, empties[i-1], empties[i-1])
+	}
+
+	return &IncrementalTree{
+		config: &incrementalConfig{
+			hash:       parameters.hash,
+			saltFunc:   parameters.saltFunc,
+			countMixIn: parameters.countMixIn,
+			depth:      depth,
+			empties:    empties,
+		},
+		hasher:   hasher,
+		frontier: newFrontier(depth),
+	}, nil
+}
+
+// Depth returns the depth of the tree.
+func (t *IncrementalTree) Depth() int {
+	return t.config.depth
+}
+
+// Count returns the number of values in the tree.
+func (t *IncrementalTree) Count() uint64 {
+	return t.frontier.count
+}
+
+// Append appends a value to the tree, returning its index.
+func (t *IncrementalTree) Append(data []byte) (uint64, error) {
+	if t.frontier.full() {
+		return 0, errors.New("tree is full")
+	}
+
+	index := t.frontier.count
+	leaf, err := t.config.leaf(t.hasher, index, data)
+	if err != nil {
+		return 0, err
+	}
+	t.frontier.append(t.hasher, leaf)
+
+	return index, nil
+}
+
+// Root returns the Merkle root of the tree.
+// If the tree mixes its count in to the root this is the root after the count has been mixed in.
+func (t *IncrementalTree) Root() []byte {
+	return t.config.root(t.hasher, t.frontier.root(t.hasher, t.config.empties), t.frontier.count)
+}
+
+// Witness returns a witness for the value most recently appended to the tree.
+func (t *IncrementalTree) Witness() (*IncrementalWitness, error) {
+	if t.frontier.count == 0 {
+		return nil, errors.New("tree is empty")
+	}
+
+	index := t.frontier.count - 1
+	siblings := make([][]byte, t.config.depth)
+	for level := 0; level < t.config.depth; level++ {
+		if (index>>level)&1 == 1 {
+			// Left siblings are complete, and held in the frontier.
+			siblings[level] = t.frontier.nodes[level]
+		}
+	}
+
+	return &IncrementalWitness{
+		config:   t.config,
+		hasher:   newHasher(t.config.hash),
+		index:    index,
+		count:    t.frontier.count,
+		siblings: siblings,
+	}, nil
+}
+
+// Index returns the index of the value of the witness.
+func (w *IncrementalWitness) Index() uint64 {
+	return w.index
+}
+
+// Count returns the number of values in the tree as known to the witness.
+func (w *IncrementalWitness) Count() uint64 {
+	return w.count
+}
+
+// Append updates the witness with a value appended to the tree.  Values must be supplied in the order they were appended.
+func (w *IncrementalWitness) Append(data []byte) error {
+	if w.count == uint64(1)<<w.config.depth {
+		return errors.New("tree is full")
+	}
+	leaf, err := w.config.leaf(w.hasher, w.count, data)
+	if err != nil {
+		return err
+	}
+
+	// The value is in the right sibling at the highest level at which its index differs from that of the witness.
+	level := bits.Len64(w.count^w.index) - 1
+	if w.cursor == nil {
+		w.cursor = newFrontier(level)
+		w.cursorLevel = level
+	}
+	w.cursor.append(w.hasher, leaf)
+	if w.cursor.full() {
+		w.siblings[w.cursorLevel] = w.cursor.root(w.hasher, w.config.empties)
+		w.cursor = nil
+	}
+	w.count++
+
+	return nil
+}
+
+// Proof returns the proof of the value of the witness against the current root of the tree.
+func (w *IncrementalWitness) Proof() *Proof {
+	hashes := make([][]byte, w.config.depth)
+	for level := range hashes {
+		switch {
+		case w.siblings[level] != nil:
+			hashes[level] = w.siblings[level]
+		case w.cursor != nil && w.cursorLevel == level:
+			hashes[level] = w.cursor.root(w.hasher, w.config.empties)
+		default:
+			hashes[level] = w.config.empties[level]
+		}
+	}
+
+	proof := newProof(hashes, w.index)
+	if w.config.countMixIn {
+		proof.Count = w.count
+	}
+
+	return proof
+}
+
+// leaf returns the leaf hash of the value at the given index.
+func (c *incrementalConfig) leaf(hasher *hasher, index uint64, data []byte) ([]byte, error) {
+	salt := saltFor(c.saltFunc, index)
+	if err := checkLeaf(c.hash, data, salt); err != nil {
+		return nil, errors.Wrap(err, "invalid data")
+	}
+
+	return hasher.hashLeaf(nil, data, salt), nil
+}
+
+// root returns the root of the tree, mixing in the count if required.
+func (c *incrementalConfig) root(hasher *hasher, root []byte, count uint64) []byte {
+	if c.countMixIn {
+		return mixInCount(hasher, root, count)
+	}
+
+	return root
+}
+
+// newFrontier creates a frontier for a tree of the given depth.
+func newFrontier(depth int) *frontier {
+	return &frontier{
+		depth: depth,
+		nodes: make([][]byte, depth+1),
+	}
+}
+
+// full returns true if all of the leaves of the frontier's tree are filled.
+func (f *frontier) full() bool {
+	return f.count == uint64(1)<<f.depth
+}
+
+// append appends a leaf to the frontier.
+func (f *frontier) append(hasher *hasher, leaf []byte) {
+	node := leaf
+	for level := 0; level <= f.depth; level++ {
+		if (f.count>>level)&1 == 0 {
+			f.nodes[level] = node
+
+			break
+		}
+		node = hasher.hashBranch(nil, f.nodes[level], node)
+	}
+	f.count++
+}
+
+// root returns the root of the frontier's tree, with leaves that are not yet filled as padding.
+func (f *frontier) root(hasher *hasher, empties [][]byte) []byte {
+	if f.full() {
+		// The root is held above the top level.
+		return f.nodes[f.depth]
+	}
+
+	node := empties[0]
+	for level := 0; level < f.depth; level++ {
+		if (f.count>>level)&1 == 1 {
+			node = hasher.hashBranch(nil, f.nodes[level], node)
+		} else {
+			node = hasher.hashBranch(nil, node, empties[level])
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,130 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+)
+
+func TestIncrementalTree(t *testing.T) {
+	tests := []struct {
+		depth  int
+		params []Parameter
+	}{
+		{
+			depth: 0,
+		},
+		{
+			depth: 4,
+		},
+		{
+			depth:  5,
+			params: []Parameter{WithHashType(keccak256.New()), WithSalt(true)},
+		},
+		{
+			// As per the Ethereum deposit contract.
+			depth:  5,
+			params: []Parameter{WithHashType(sha3.New256()), WithCountMixIn(true)},
+		},
+		{
+			depth:  3,
+			params: []Parameter{WithPadding(PaddingEmptyHash)},
+		},
+	}
+
+	for i, test := range tests {
+		tree, err := NewIncrementalTree(test.depth, test.params...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, test.depth, tree.Depth(), fmt.Sprintf("failed at test %d", i))
+
+		width := 1 << test.depth
+		data := diffData(width)
+		witnesses := make([]*IncrementalWitness, 0, width)
+		for j := range data {
+			index, err := tree.Append(data[j])
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, uint64(j), index, fmt.Sprintf("failed at test %d", i))
+			for _, witness := range witnesses {
+				require.NoError(t, witness.Append(data[j]), fmt.Sprintf("failed at test %d", i))
+			}
+			witness, err := tree.Witness()
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			witnesses = append(witnesses, witness)
+
+			expected, err := NewTree(append([]Parameter{WithData(data[:j+1]), WithWidth(uint64(width))}, test.params...)...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, uint64(j+1), tree.Count(), fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, expected.Root(), tree.Root(), fmt.Sprintf("failed at test %d with %d values", i, j+1))
+
+			// All witnesses remain valid against the current root.
+			for k, witness := range witnesses {
+				assert.Equal(t, uint64(k), witness.Index(), fmt.Sprintf("failed at test %d", i))
+				assert.Equal(t, tree.Count(), witness.Count(), fmt.Sprintf("failed at test %d", i))
+				proof := witness.Proof()
+				expectedProof, err := expected.GenerateProofWithIndex(uint64(k), 0)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.Equal(t, expectedProof, proof, fmt.Sprintf("failed at test %d with %d values for witness %d", i, j+1, k))
+				verified, err := VerifyProofUsingSaltFunc(data[k], expected.saltFunc(), proof, [][]byte{tree.Root()}, expected.Hash)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.True(t, verified, fmt.Sprintf("failed at test %d with %d values for witness %d", i, j+1, k))
+			}
+		}
+
+		_, err = tree.Append([]byte("extra"))
+		assert.EqualError(t, err, "tree is full", fmt.Sprintf("failed at test %d", i))
+		assert.EqualError(t, witnesses[0].Append([]byte("extra")), "tree is full", fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestIncrementalTreeState(t *testing.T) {
+	tree, err := NewIncrementalTree(32)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := tree.Append([]byte(fmt.Sprintf("value %d", i)))
+		require.NoError(t, err)
+	}
+	// The state is a single node per level, regardless of the number of values.
+	assert.Len(t, tree.frontier.nodes, 33)
+	assert.Len(t, tree.config.empties, 33)
+}
+
+func TestIncrementalTreeErrors(t *testing.T) {
+	_, err := NewIncrementalTree(-1)
+	assert.EqualError(t, err, "depth must be between 0 and 63")
+	_, err = NewIncrementalTree(64)
+	assert.EqualError(t, err, "depth must be between 0 and 63")
+	_, err = NewIncrementalTree(4, WithData(diffData(2)))
+	assert.EqualError(t, err, "problem with parameters: incremental tree does not use the data parameter")
+	_, err = NewIncrementalTree(4, WithSorted(true))
+	assert.EqualError(t, err, "problem with parameters: incremental tree cannot create sorted trees")
+	_, err = NewIncrementalTree(4, WithPadding(PaddingDuplicateLast))
+	assert.EqualError(t, err, "problem with parameters: incremental trees must use zero-hash or empty-hash padding")
+	_, err = NewIncrementalTree(4, WithArity(4))
+	assert.EqualError(t, err, "problem with parameters: incremental trees must have an arity of 2")
+	_, err = NewIncrementalTree(4, WithWidth(16))
+	assert.EqualError(t, err, "problem with parameters: incremental trees do not use the width parameter")
+	_, err = NewIncrementalTree(4, WithSpillDir("/tmp"))
+	assert.EqualError(t, err, "problem with parameters: incremental trees do not use the spill directory parameter")
+
+	tree, err := NewIncrementalTree(4)
+	require.NoError(t, err)
+	_, err = tree.Witness()
+	assert.EqualError(t, err, "tree is empty")
+}
@@ -15,6 +15,8 @@
 package keccak256
 
 import (
+	"hash"
+
 	"golang.org/x/crypto/sha3"
 )
 
@@ -47,3 +49,8 @@ func (*Keccak256) Hash(data ...[]byte) []byte {
 
 	return hash.Sum(nil)
 }
+
+// NewHasher returns a new streaming Keccak-256 hasher.
+func (*Keccak256) NewHasher() hash.Hash {
+	return sha3.NewLegacyKeccak256()
+}
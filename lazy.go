@@ -0,0 +1,285 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DataFunc returns the value at the given index of the data of a lazy tree.
+type DataFunc func(index uint64) ([]byte, error)
+
+// LazyTree is a Merkle tree whose nodes are computed only when they are needed to provide its root, a proof or a pollard, and
+// are then cached.  Its data can be supplied with WithData(), or fetched when required with WithDataFunc().
+//
+// The root of a lazy tree is the same as that of a MerkleTree created from the same data with the same parameters, and its
+// proofs are verified in the same way.  Lazy trees cannot be sorted, as sorting requires all leaves to be hashed up front.
+//
+// A lazy tree is safe for concurrent use.
+type LazyTree struct {
+	hash       HashType
+	saltFunc   SaltFunc
+	salts      [][]byte
+	padding    Padding
+	countMixIn bool
+	arity      int
+	count      uint64
+	data       DataFunc
+	leafOffset uint64
+
+	mu     sync.Mutex
+	hasher *hasher
+	nodes  map[uint64][]byte
+	// empties are the roots of subtrees of each height that contain only padding.
+	empties [][]byte
+}
+
+// NewLazyTree creates a new lazy tree.  No hashing takes place until the root, a proof or a pollard is requested.
+func NewLazyTree(params ...Parameter) (*LazyTree, error) {
+	parameters, err := parseAndCheckTreeParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+	if parameters.leafHashes != nil {
+		return nil, errors.New("lazy trees must be created from data")
+	}
+	if parameters.sorted {
+		return nil, errors.New("lazy trees cannot be sorted")
+	}
+
+	t := &LazyTree{
+		hash:       parameters.hash,
+		saltFunc:   parameters.saltFunc,
+		salts:      parameters.salts,
+		padding:    parameters.padding,
+		countMixIn: parameters.countMixIn,
+		arity:      parameters.arity,
+		count:      parameters.valueCount(),
+		data:       parameters.dataFunc,
+		hasher:     newHasher(parameters.hash),
+		nodes:      make(map[uint64][]byte),
+	}
+	if t.data == nil {
+		data := parameters.data
+		t.data = func(index uint64) ([]byte, error) {
+			return data[index], nil
+		}
+	}
+
+	arity := arityOf(t.arity)
+	depth := depthFor(t.count, arity)
+	if width := depthFor(parameters.width, arity); width > depth {
+		depth = width
+	}
+	t.leafOffset = levelStart(depth, arity)
+
+	return t, nil
+}
+
+// Count returns the number of values in the tree.
+func (t *LazyTree) Count() uint64 {
+	return t.count
+}
+
+// Root returns the Merkle root of the tree.
+// If the tree mixes its count in to the root this is the root after the count has been mixed in.
+func (t *LazyTree) Root() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root, err := t.node(1)
+	if err != nil {
+		return nil, err
+	}
+	if t.countMixIn {
+		root = mixInCount(t.hasher, root, t.count)
+	}
+
+	return root, nil
+}
+
+// Pollard returns the Merkle root plus branches to a certain height, as per MerkleTree.Pollard().
+func (t *LazyTree) Pollard(height int) ([][]byte, error) {
+	if height < 0 || levelStart(height, arityOf(t.arity)) > t.leafOffset {
+		return nil, errors.New("height out of range")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pollard := make([][]byte, 0)
+	for i := uint64(1); i < levelStart(height+1, arityOf(t.arity)); i++ {
+		hash, err := t.node(i)
+		if err != nil {
+			return nil, err
+		}
+		pollard = append(pollard, hash)
+	}
+	if t.countMixIn && height == 0 {
+		pollard[0] = mixInCount(t.hasher, pollard[0], t.count)
+	}
+
+	return pollard, nil
+}
+
+// GenerateProof generates the proof for a piece of data.
+// Height is the height of the pollard to verify the proof.  If using the Merkle root to verify this should be 0.
+// As the tree does not hold an index of its data, the data is fetched in order until it is found; GenerateProofWithIndex() should
+// be used if the index is known.  If the data is not present in the tree this will return an error.
+func (t *LazyTree) GenerateProof(data []byte, height int) (*Proof, error) {
+	for i := uint64(0); i < t.count; i++ {
+		value, err := t.data(i)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch data")
+		}
+		if bytes.Equal(value, data) {
+			return t.GenerateProofWithIndex(i, height)
+		}
+	}
+
+	return nil, errors.New("data not found")
+}
+
+// GenerateProofWithIndex generates the proof for the data at the given index, as per MerkleTree.GenerateProofWithIndex().
+// Only the nodes on the path from the leaf to the root and their siblings are computed.
+func (t *LazyTree) GenerateProofWithIndex(index uint64, height int) (*Proof, error) {
+	if index >= t.count {
+		return nil, errors.New("index out of range")
+	}
+	arity := arityOf(t.arity)
+	if height < 0 || levelStart(height, arity) > t.leafOffset {
+		return nil, errors.New("height out of range")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashes := make([][]byte, 0)
+	minI := levelStart(height+1, arity)
+	for i := index + t.leafOffset; i >= minI; i = parentOf(i, arity) {
+		first := firstChild(parentOf(i, arity), arity)
+		for j := first; j < first+arity; j++ {
+			if j != i {
+				hash, err := t.node(j)
+				if err != nil {
+					return nil, err
+				}
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+
+	proof := newProof(hashes, index)
+	proof.Arity = t.arity
+	if t.countMixIn && height == 0 {
+		proof.Count = t.count
+	}
+
+	return proof, nil
+}
+
+// node returns the hash of the node at the given index, computing and caching it if required.
+func (t *LazyTree) node(index uint64) ([]byte, error) {
+	if hash, exists := t.nodes[index]; exists {
+		return hash, nil
+	}
+
+	// Subtrees that contain only padding are not cached, as they are the same throughout the tree.
+	arity := arityOf(t.arity)
+	height := 0
+	first := index
+	for first < t.leafOffset {
+		first = firstChild(first, arity)
+		height++
+	}
+	if first-t.leafOffset >= t.count {
+		return t.empty(height)
+	}
+
+	var hash []byte
+	if height == 0 {
+		value, err := t.data(index - t.leafOffset)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch data")
+		}
+		salt := t.leafSalt(index - t.leafOffset)
+		if err := checkLeaf(t.hash, value, salt); err != nil {
+			return nil, fmt.Errorf("invalid data at index %d: %w", index-t.leafOffset, err)
+		}
+		hash = t.hasher.hashLeaf(nil, value, salt)
+	} else {
+		children := make([][]byte, arity)
+		for i := range children {
+			child, err := t.node(firstChild(index, arity) + uint64(i))
+			if err != nil {
+				return nil, err
+			}
+			children[i] = child
+		}
+		if children[len(children)-1] == nil {
+			// As per PaddingNone, a branch without a right child takes the hash of its left child.
+			hash = children[0]
+		} else {
+			hash = t.hasher.hashChildren(nil, children)
+		}
+	}
+	t.nodes[index] = hash
+
+	return hash, nil
+}
+
+// empty returns the root of a subtree of the given height that contains only padding.
+func (t *LazyTree) empty(height int) ([]byte, error) {
+	if t.empties == nil {
+		var pad []byte
+		if t.padding == PaddingDuplicateLast {
+			last, err := t.node(t.leafOffset + t.count - 1)
+			if err != nil {
+				return nil, err
+			}
+			pad = last
+		} else {
+			pad = paddingLeaf(t.padding, t.hash, nil)
+		}
+		t.empties = [][]byte{pad}
+	}
+	for len(t.empties) <= height {
+		below := t.empties[len(t.empties)-1]
+		if below == nil {
+			t.empties = append(t.empties, nil)
+
+			continue
+		}
+		children := make([][]byte, arityOf(t.arity))
+		for i := range children {
+			children[i] = below
+		}
+		t.empties = append(t.empties, t.hasher.hashChildren(nil, children))
+	}
+
+	return t.empties[height], nil
+}
+
+// leafSalt returns the salt for the value at the given index, or nil if the tree is not salted.
+func (t *LazyTree) leafSalt(index uint64) []byte {
+	if t.salts != nil {
+		return t.salts[index]
+	}
+
+	return saltFor(t.saltFunc, index)
+}
@@ -0,0 +1,176 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestLazyTree(t *testing.T) {
+	tests := []struct {
+		values int
+		params []Parameter
+	}{
+		{
+			values: 1,
+		},
+		{
+			values: 7,
+			params: []Parameter{WithHashType(keccak256.New()), WithSalt(true)},
+		},
+		{
+			values: 10,
+			params: []Parameter{WithPadding(PaddingEmptyHash), WithCountMixIn(true)},
+		},
+		{
+			values: 10,
+			params: []Parameter{WithPadding(PaddingDuplicateLast)},
+		},
+		{
+			values: 11,
+			params: []Parameter{WithPadding(PaddingNone)},
+		},
+		{
+			values: 20,
+			params: []Parameter{WithArity(4), WithWidth(64)},
+		},
+		{
+			values: 5,
+			params: []Parameter{WithRandomSalts(true)},
+		},
+	}
+
+	for i, test := range tests {
+		data := diffData(test.values)
+		if i == len(tests)-1 {
+			// Random salts must be shared between the trees.
+			tree, err := NewTree(append([]Parameter{WithData(data)}, test.params...)...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			test.params = []Parameter{WithSalts(tree.Salts)}
+		}
+		expected, err := NewTree(append([]Parameter{WithData(data)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		fetches := 0
+		fetch := func(index uint64) ([]byte, error) {
+			fetches++
+
+			return data[index], nil
+		}
+		fetched, err := NewLazyTree(append([]Parameter{WithDataFunc(uint64(test.values), fetch)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		inMemory, err := NewLazyTree(append([]Parameter{WithData(data)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		for _, tree := range []*LazyTree{fetched, inMemory} {
+			assert.Equal(t, uint64(test.values), tree.Count(), fmt.Sprintf("failed at test %d", i))
+
+			// Proofs are available before the root is computed.
+			proof, err := tree.GenerateProofWithIndex(uint64(test.values-1), 0)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			expectedProof, err := expected.GenerateProofWithIndex(uint64(test.values-1), 0)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, expectedProof, proof, fmt.Sprintf("failed at test %d", i))
+
+			root, err := tree.Root()
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, expected.Root(), root, fmt.Sprintf("failed at test %d", i))
+
+			for index := range data {
+				proof, err := tree.GenerateProof(data[index], 0)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				expectedProof, err := expected.GenerateProofWithIndex(uint64(index), 0)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.Equal(t, expectedProof, proof, fmt.Sprintf("failed at test %d index %d", i, index))
+			}
+
+			pollard, err := tree.Pollard(0)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, expected.Pollard(0), pollard, fmt.Sprintf("failed at test %d", i))
+			if test.values > 1 {
+				pollard, err := tree.Pollard(1)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.Equal(t, expected.Pollard(1), pollard, fmt.Sprintf("failed at test %d", i))
+			}
+		}
+		// Each value is fetched and hashed at most once; further fetches are only to search for data.
+		assert.LessOrEqual(t, fetches, test.values+test.values*(test.values+1)/2, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestLazyTreeOnDemand(t *testing.T) {
+	fetched := make(map[uint64]bool)
+	tree, err := NewLazyTree(WithDataFunc(1<<40, func(index uint64) ([]byte, error) {
+		fetched[index] = true
+
+		return []byte(fmt.Sprintf("value %d", index)), nil
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, fetched)
+
+	// A proof to a pollard in a very large tree requires only the other values below its node in the pollard.
+	proof, err := tree.GenerateProofWithIndex(12345, 30)
+	require.NoError(t, err)
+	assert.Len(t, proof.Hashes, 10)
+	assert.Len(t, fetched, 1023)
+	assert.False(t, fetched[12345])
+	for index := range fetched {
+		assert.Equal(t, uint64(12345)/1024, index/1024)
+	}
+}
+
+func TestLazyTreeErrors(t *testing.T) {
+	failing := func(index uint64) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	}
+
+	_, err := NewLazyTree(WithDataFunc(0, failing))
+	assert.EqualError(t, err, "problem with parameters: tree must have at least 1 piece of data")
+	_, err = NewLazyTree(WithDataFunc(2, failing), WithData(diffData(2)))
+	assert.EqualError(t, err, "problem with parameters: cannot use both data and a data function")
+	_, err = NewLazyTree(WithDataFunc(2, failing), WithSalts([][]byte{{0x01}}))
+	assert.EqualError(t, err, "problem with parameters: salts must be the same length as data")
+	_, err = NewLazyTree(WithLeafHashes([][]byte{make([]byte, 32)}))
+	assert.EqualError(t, err, "lazy trees must be created from data")
+	_, err = NewLazyTree(WithData(diffData(2)), WithSorted(true))
+	assert.EqualError(t, err, "lazy trees cannot be sorted")
+	_, err = NewTree(WithDataFunc(2, failing))
+	assert.EqualError(t, err, "merkle tree does not use the data function parameter; use NewLazyTree()")
+
+	tree, err := NewLazyTree(WithDataFunc(2, failing))
+	require.NoError(t, err)
+	_, err = tree.Root()
+	assert.EqualError(t, err, "failed to fetch data: unavailable")
+	_, err = tree.Pollard(1)
+	assert.EqualError(t, err, "failed to fetch data: unavailable")
+	_, err = tree.Pollard(2)
+	assert.EqualError(t, err, "height out of range")
+	_, err = tree.GenerateProof([]byte("value"), 0)
+	assert.EqualError(t, err, "failed to fetch data: unavailable")
+	_, err = tree.GenerateProofWithIndex(2, 0)
+	assert.EqualError(t, err, "index out of range")
+	_, err = tree.GenerateProofWithIndex(0, 2)
+	assert.EqualError(t, err, "height out of range")
+
+	tree, err = NewLazyTree(WithData(diffData(2)))
+	require.NoError(t, err)
+	_, err = tree.GenerateProof([]byte("missing"), 0)
+	assert.EqualError(t, err, "data not found")
+}
@@ -0,0 +1,96 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"github.com/pkg/errors"
+)
+
+// buildLookup builds the map of values to their indices in the tree.
+// If the tree was created from leaf hashes the map is of leaf hashes to their indices.
+func (t *MerkleTree) buildLookup() {
+	values := t.values()
+	t.lookup = make(map[string][]uint64, values)
+	leafOffset := t.leafOffset()
+	for i := uint64(0); i < values; i++ {
+		var key string
+		if t.Data != nil {
+			key = string(t.Data[i])
+		} else {
+			key = string(t.Nodes[leafOffset+i])
+		}
+		t.lookup[key] = append(t.lookup[key], i)
+	}
+}
+
+// IndicesOf returns the indices of all occurrences of the data in the tree, in ascending order.
+// If the tree was created from leaf hashes the data is the leaf hash.
+//
+// The lookup is built on first use, after which this is O(1).  The lookup reflects the data of the tree at the time it was
+// built, so the tree's data should not be altered after it is created.  The indices returned are a copy, so can be altered by
+// the caller.
+func (t *MerkleTree) IndicesOf(data []byte) ([]uint64, error) {
+	indices, err := t.indicesOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]uint64{}, indices...), nil
+}
+
+// indicesOf returns the indices of all occurrences of the data in the tree from the lookup, which must not be altered.
+func (t *MerkleTree) indicesOf(data []byte) ([]uint64, error) {
+	t.lookupOnce.Do(t.buildLookup)
+
+	indices, exists := t.lookup[string(data)]
+	if !exists {
+		return nil, errors.New("data not found")
+	}
+
+	return indices, nil
+}
+
+// Index of the data in the MerkleTree.
+// An error is returned if the data is present more than once, as the index is ambiguous.
+func (t *MerkleTree) indexOf(input []byte) (uint64, error) {
+	indices, err := t.indicesOf(input)
+	if err != nil {
+		return 0, err
+	}
+	if len(indices) > 1 {
+		return 0, errors.New("data present at multiple indices")
+	}
+
+	return indices[0], nil
+}
+
+// GenerateProofs generates a proof for each occurrence of a piece of data in the tree, in ascending order of index.
+// Height is the height of the pollard to verify the proofs.  If using the Merkle root to verify this should be 0.
+// If the data is not present in the tree this will return an error.
+func (t *MerkleTree) GenerateProofs(data []byte, height int) ([]*Proof, error) {
+	indices, err := t.indicesOf(data)
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([]*Proof, len(indices))
+	for i, index := range indices {
+		proofs[i], err = t.GenerateProofWithIndex(index, height)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return proofs, nil
+}
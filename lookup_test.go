@@ -0,0 +1,166 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestIndicesOf(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Foo"), []byte("Baz"), []byte("Foo")}
+
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		data    []byte
+		indices []uint64
+		err     string
+	}{
+		{
+			data:    []byte("Foo"),
+			indices: []uint64{0, 2, 4},
+		},
+		{
+			data:    []byte("Bar"),
+			indices: []uint64{1},
+		},
+		{
+			data:    []byte("Baz"),
+			indices: []uint64{3},
+		},
+		{
+			data: []byte("Qux"),
+			err:  "data not found",
+		},
+	}
+
+	for i, test := range tests {
+		indices, err := tree.IndicesOf(test.data)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, fmt.Sprintf("failed at test %d", i))
+		} else {
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, test.indices, indices, fmt.Sprintf("failed at test %d", i))
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Foo"), []byte("Baz")}
+
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+
+	_, err = tree.GenerateProof([]byte("Foo"), 0)
+	assert.EqualError(t, err, "data present at multiple indices")
+	_, err = tree.GenerateMultiProof([][]byte{[]byte("Bar"), []byte("Foo")})
+	assert.EqualError(t, err, "data present at multiple indices")
+
+	proofs, err := tree.GenerateProofs([]byte("Foo"), 0)
+	require.NoError(t, err)
+	require.Len(t, proofs, 2)
+	for i, index := range []uint64{0, 2} {
+		assert.Equal(t, index, proofs[i].Index)
+		verified, err := VerifyProofUsing([]byte("Foo"), false, proofs[i], [][]byte{tree.Root()}, tree.Hash)
+		require.NoError(t, err)
+		assert.True(t, verified, fmt.Sprintf("failed at proof %d", i))
+	}
+
+	proof, err := tree.GenerateProof([]byte("Bar"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), proof.Index)
+	_, err = tree.GenerateProofs([]byte("Qux"), 0)
+	assert.EqualError(t, err, "data not found")
+}
+
+func TestIndicesOfLeafHashes(t *testing.T) {
+	hashType := keccak256.New()
+	leafHashes := [][]byte{hashType.Hash([]byte("Foo")), hashType.Hash([]byte("Bar")), hashType.Hash([]byte("Baz"))}
+
+	tree, err := NewTree(WithLeafHashes(leafHashes), WithHashType(hashType))
+	require.NoError(t, err)
+
+	for i := range leafHashes {
+		proof, err := tree.GenerateProof(leafHashes[i], 0)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.Equal(t, uint64(i), proof.Index, fmt.Sprintf("failed at index %d", i))
+	}
+}
+
+func TestIndicesOfCopy(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Foo")}
+
+	tree, err := NewTree(WithData(data))
+	require.NoError(t, err)
+
+	indices, err := tree.IndicesOf(data[0])
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 2}, indices)
+
+	// Altering the returned indices does not alter later lookups.
+	indices[0] = 1
+	proofs, err := tree.GenerateProofs(data[0], 0)
+	require.NoError(t, err)
+	require.Len(t, proofs, 2)
+	assert.Equal(t, uint64(0), proofs[0].Index)
+	indices, err = tree.IndicesOf(data[0])
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 2}, indices)
+}
+
+func TestIndicesOfConcurrent(t *testing.T) {
+	data := make([][]byte, 1000)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for j := offset; j < len(data); j += 8 {
+				indices, err := tree.IndicesOf(data[j])
+				assert.NoError(t, err)
+				assert.Equal(t, []uint64{uint64(j)}, indices)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkGenerateMultiProof(b *testing.B) {
+	data := make([][]byte, 100000)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()))
+	require.NoError(b, err)
+	proofData := data[:1000]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := tree.GenerateMultiProof(proofData)
+		require.NoError(b, err)
+	}
+}
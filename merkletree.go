@@ -31,18 +31,22 @@
 // # Implementation notes
 //
 // The tree pads its values to the next highest power of 2; values not supplied are treated as null with a value hash of 0.  This
-// can be seen graphically by generating a DOT representation of the graph with DOT().
+// can be seen graphically by generating a DOT representation of the graph with DOT().  Other padding strategies, including an
+// unbalanced tree without padding, can be selected with WithPadding().
 //
-// If salting is enabled it appends an 4-byte value to each piece of data.  The value is the binary representation of the index in
-// big-endian form.  Note that if there are more than 2^32 values in the tree the salt will wrap, being modulo 2^32
+// Trees are binary by default.  WithArity() creates trees in which each branch has 4, 8 or 16 children, reducing the depth of the
+// tree at the cost of larger proofs; each level of a proof then contains all of the siblings of the node at that level.
+//
+// If salting is enabled it appends a value generated from the index to each piece of data.  By default this is the 4-byte
+// big-endian representation of the index, which wraps if there are more than 2^32 values in the tree; trees that may grow beyond
+// this should use WithSaltFunc(IndexSalt64) for an 8-byte salt.
 package merkletree
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/hex"
-	"math"
 	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -51,55 +55,73 @@ import (
 type MerkleTree struct {
 	// if Salt is true the Data values are salted with their index
 	Salt bool `json:"salt"`
+	// SaltFunc generates the salt for each Data value if Salt is true
+	SaltFunc SaltFunc `json:"-"`
+	// Salts are the per-value salts of the Data values, if present
+	Salts [][]byte `json:"salts,omitempty"`
 	// if Sorted is true, the Hash values are Sorted before hashing branch Nodes
 	Sorted bool `json:"sorted"`
 	// Hash is a pointer to the hashing struct
 	Hash HashType `json:"hash_type"`
-	// Data is the Data from which the Merkle tree is created
+	// Data is the Data from which the Merkle tree is created, or nil if the tree was created from leaf hashes
 	Data [][]byte `json:"data"`
+	// Count is the number of values in the Merkle tree
+	Count uint64 `json:"count,omitempty"`
+	// Positions are the indices in the tree of each value as originally supplied, if the tree is sorted
+	Positions []uint64 `json:"positions,omitempty"`
+	// Padding is the strategy used to fill the leaves after the values
+	Padding Padding `json:"padding,omitempty"`
+	// if CountMixIn is true the Count is mixed in to the root, which is held in Nodes[0]
+	CountMixIn bool `json:"count_mix_in,omitempty"`
+	// Arity is the number of children of each branch Node, or 0 if the tree is binary
+	Arity int `json:"arity,omitempty"`
 	// Nodes are the leaf and branch Nodes of the Merkle tree
 	Nodes [][]byte `json:"nodes"`
+
+	// lookup maps values, or leaf hashes if there is no data, to their indices.
+	lookup     map[string][]uint64
+	lookupOnce sync.Once
+	// originalIndices are the indices of the values as originally supplied, in the order of the tree, if the tree is sorted.
+	originalIndices []uint64
 }
 
 // A container which gives us the ability to sort the hashes by value
-// while maintaining the relative positions of the data and it's hash.
+// while tracking the original positions of the data and its hash.
 type hashSorter struct {
-	// data from which the hashes are generated
-	data [][]byte
 	// Hashes of the data
 	hashes [][]byte
+	// order are the original indices of the hashes
+	order []uint64
 }
 
-// Len length of the data slice.
+// Len length of the hashes slice.
 func (s hashSorter) Len() int {
-	return len(s.data)
+	return len(s.hashes)
 }
 
-// Swap the given indicies in both the data and leaf slices.
+// Swap the given indicies in both the hashes and order slices.
 func (s hashSorter) Swap(i, j int) {
-	s.data[i], s.data[j] = s.data[j], s.data[i]
 	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
+	s.order[i], s.order[j] = s.order[j], s.order[i]
 }
 
 // Compares the hash indicies, returns true if i is less than j.
+// Equal hashes are ordered by their original index, so the sort is deterministic.
 func (s hashSorter) Less(i, j int) bool {
-	return bytes.Compare(s.hashes[i], s.hashes[j]) == -1
-}
-
-// Index of the data in the MerkleTree.
-func (t *MerkleTree) indexOf(input []byte) (uint64, error) {
-	for i, data := range t.Data {
-		if bytes.Equal(data, input) {
-			return uint64(i), nil
-		}
+	switch bytes.Compare(s.hashes[i], s.hashes[j]) {
+	case -1:
+		return true
+	case 1:
+		return false
+	default:
+		return s.order[i] < s.order[j]
 	}
-
-	return 0, errors.New("data not found")
 }
 
 // GenerateProof generates the proof for a piece of data.
 // Height is the height of the pollard to verify the proof.  If using the Merkle root to verify this should be 0.
-// If the data is not present in the tree this will return an error.
+// If the data is not present in the tree, or is present more than once, this will return an error; use GenerateProofs() to obtain
+// proofs for data that may be present more than once.
 // If the data is present in the tree this will return the hashes for each level in the tree and the index of the value in the tree.
 func (t *MerkleTree) GenerateProof(data []byte, height int) (*Proof, error) {
 	// Find the index of the data
@@ -112,29 +134,79 @@ func (t *MerkleTree) GenerateProof(data []byte, height int) (*Proof, error) {
 }
 
 // GenerateProofWithIndex generates the proof for the data at the given index.
+// If the tree is sorted the index is that of the data in the tree; use GenerateProofWithOriginalIndex() for the index of the data
+// as originally supplied.
 // It is faster than GenerateProof() if the index is already known.
 // Height is the height of the pollard to verify the proof.  If using the Merkle root to verify this should be 0.
 // If the index is out of range this will return an error.
 // If the data is present in the tree this will return the hashes for each level in the tree and the index of the value in the tree.
+// If the tree mixes its count in to the root and the height is 0 the proof also contains the count.
+// Proofs are not supported for sorted trees salted by index, as values are salted by their original index which verifiers cannot
+// obtain from the proof; use GenerateDisclosureWithIndex() instead.
 func (t *MerkleTree) GenerateProofWithIndex(index uint64, height int) (*Proof, error) {
-	if index >= uint64(len(t.Data)) {
+	if t.Salt && t.Sorted {
+		return nil, errors.New("proofs are not supported for sorted trees salted by index")
+	}
+
+	return t.generateProof(index, height)
+}
+
+// generateProof generates the proof for the data at the given index in the tree.
+func (t *MerkleTree) generateProof(index uint64, height int) (*Proof, error) {
+	if index >= t.values() {
 		return nil, errors.New("index out of range")
 	}
 
-	proofLen := int(math.Ceil(math.Log2(float64(len(t.Data))))) - height
-	hashes := make([][]byte, proofLen)
+	arity := t.arity()
+	proofLen := (depthFor(t.values(), arity) - height) * int(arity-1)
+	hashes := make([][]byte, 0, proofLen)
+
+	// Each level of the proof contains the siblings of the node, in order.
+	minI := levelStart(height+1, arity)
+	for i := index + t.leafOffset(); i >= minI; i = parentOf(i, arity) {
+		first := firstChild(parentOf(i, arity), arity)
+		for j := first; j < first+arity; j++ {
+			if j != i {
+				hashes = append(hashes, t.Nodes[j])
+			}
+		}
+	}
+
+	proof := newProof(hashes, index)
+	proof.Arity = t.Arity
+	if t.CountMixIn && height == 0 {
+		proof.Count = t.values()
+	}
+
+	return proof, nil
+}
+
+// TreeIndex returns the index in the tree of the value at the given index of the data as originally supplied.
+// This is only different from the original index if the tree is sorted.
+func (t *MerkleTree) TreeIndex(originalIndex uint64) (uint64, error) {
+	if originalIndex >= t.values() {
+		return 0, errors.New("index out of range")
+	}
+	if t.Positions == nil {
+		return originalIndex, nil
+	}
+
+	return t.Positions[originalIndex], nil
+}
 
-	cur := 0
-	minI := uint64(math.Pow(2, float64(height+1))) - 1
-	for i := index + uint64(len(t.Nodes)/2); i > minI; i /= 2 {
-		hashes[cur] = t.Nodes[i^1]
-		cur++
+// GenerateProofWithOriginalIndex generates the proof for the data at the given index of the data as originally supplied.
+// This is the same as GenerateProofWithIndex() unless the tree is sorted.
+func (t *MerkleTree) GenerateProofWithOriginalIndex(originalIndex uint64, height int) (*Proof, error) {
+	index, err := t.TreeIndex(originalIndex)
+	if err != nil {
+		return nil, err
 	}
 
-	return newProof(hashes, index), nil
+	return t.GenerateProofWithIndex(index, height)
 }
 
 // GenerateMultiProof generates the proof for multiple pieces of data.
+// If any of the data is not present in the tree, or is present more than once, this will return an error.
 func (t *MerkleTree) GenerateMultiProof(data [][]byte) (*MultiProof, error) {
 	indices := make([]uint64, len(data))
 
@@ -152,87 +224,167 @@ func (t *MerkleTree) GenerateMultiProof(data [][]byte) (*MultiProof, error) {
 
 // GenerateMultiProofWithIndices generates the proof for multiple pieces of data.
 func (t *MerkleTree) GenerateMultiProofWithIndices(indices []uint64) (*MultiProof, error) {
-	hashes := make([][][]byte, len(indices))
+	if t.Salts != nil {
+		return nil, errors.New("multiproofs are not supported for trees with per-value salts")
+	}
+	if t.Salt && t.Sorted {
+		return nil, errors.New("multiproofs are not supported for sorted trees salted by index")
+	}
 
-	// Step 1: generate individual proofs.
-	for i, leafIndex := range indices {
-		tmpProof, err := t.GenerateProofWithIndex(leafIndex, 0)
-		if err != nil {
-			return nil, err
+	// Step 1: ensure that all indices are in the tree.
+	for _, index := range indices {
+		if index >= t.values() {
+			return nil, errors.New("index out of range")
 		}
-		hashes[i] = tmpProof.Hashes
 	}
 
-	// Step 2: combine the hashes across all proofs and highlight all calculated indices.
+	// Step 2: combine the siblings across all proofs and highlight all calculated indices.
+	arity := t.arity()
+	leafOffset := t.leafOffset()
 	proofHashes := make(map[uint64][]byte)
 	calculatedIndices := make([]bool, len(t.Nodes))
-	for i, index := range indices {
-		hashNum := 0
-		for j := index + uint64(math.Ceil(float64(len(t.Nodes))/2)); j > 1; j /= 2 {
-			proofHashes[j^1] = hashes[i][hashNum]
+	for _, index := range indices {
+		for j := index + leafOffset; j > 1; j = parentOf(j, arity) {
+			first := firstChild(parentOf(j, arity), arity)
+			for k := first; k < first+arity; k++ {
+				if k != j {
+					proofHashes[k] = t.Nodes[k]
+				}
+			}
 			calculatedIndices[j] = true
-			hashNum++
 		}
 	}
 
 	// Step 3: remove any hashes that can be calculated.
-	for _, index := range indices {
-		for j := index + uint64(math.Ceil(float64(len(t.Nodes))/2)); j > 1; j /= 2 {
-			if calculatedIndices[j^1] {
-				delete(proofHashes, j^1)
-			}
+	for index := range proofHashes {
+		if calculatedIndices[index] {
+			delete(proofHashes, index)
 		}
 	}
 
 	return NewMultiProof(
 		WithHashes(proofHashes),
-		WithSalt(t.Salt),
+		WithSaltFunc(t.saltFunc()),
 		WithSorted(t.Sorted),
 		WithHashType(t.Hash),
 		WithIndices(indices),
-		WithValues(uint64(len(t.Nodes)/2)),
+		WithValues(leafOffset),
+		WithCount(t.mixedInCount()),
+		WithArity(t.Arity),
 	)
 }
 
+// GenerateMultiProofWithOriginalIndices generates the proof for the data at the given indices of the data as originally supplied.
+// This is the same as GenerateMultiProofWithIndices() unless the tree is sorted.
+func (t *MerkleTree) GenerateMultiProofWithOriginalIndices(originalIndices []uint64) (*MultiProof, error) {
+	indices := make([]uint64, len(originalIndices))
+	for i := range originalIndices {
+		index, err := t.TreeIndex(originalIndices[i])
+		if err != nil {
+			return nil, err
+		}
+		indices[i] = index
+	}
+
+	return t.GenerateMultiProofWithIndices(indices)
+}
+
 // NewTree creates a new merkle tree using the provided information.
 func NewTree(params ...Parameter) (*MerkleTree, error) {
 	parameters, err := parseAndCheckTreeParameters(params...)
 	if err != nil {
 		return nil, errors.Wrap(err, "problem with parameters")
 	}
+	if parameters.dataFunc != nil {
+		return nil, errors.New("merkle tree does not use the data function parameter; use NewLazyTree()")
+	}
 
-	branchesLen := int(math.Exp2(math.Ceil(math.Log2(float64(len(parameters.data))))))
-
-	// We pad our data length up to the power of 2.
-	nodes := make([][]byte, branchesLen*2)
+	values := len(parameters.data)
+	if parameters.leafHashes != nil {
+		values = len(parameters.leafHashes)
+	}
+	arity := arityOf(parameters.arity)
+	depth := depthFor(uint64(values), arity)
+	if width := depthFor(parameters.width, arity); width > depth {
+		depth = width
+	}
+	branchesLen := int(levelStart(depth, arity))
+
+	// We pad our data length up to the power of the arity.
+	nodes := make([][]byte, levelStart(depth+1, arity))
+
+	hasher := newHasher(parameters.hash)
+	if hasher.streaming() {
+		// Pre-allocate storage for all nodes so that the hasher can write directly to it.
+		// Node 0 is unused.
+		hashLength := parameters.hash.HashLength()
+		storage := make([]byte, len(nodes)*hashLength)
+		for i := 1; i < len(nodes); i++ {
+			nodes[i] = storage[i*hashLength : i*hashLength : (i+1)*hashLength]
+		}
+	}
 
 	// We put the leaves after the branches in the slice of nodes.
-	createLeaves(
-		parameters.data,
-		nodes[branchesLen:branchesLen+len(parameters.data)],
-		parameters.hash,
-		parameters.salt,
-		parameters.sorted,
-	)
-	// Pad the space left after the leaves.
-	for i := len(parameters.data) + branchesLen; i < len(nodes); i++ {
-		nodes[i] = make([]byte, parameters.hash.HashLength())
+	if parameters.leafHashes != nil {
+		copyLeaves(
+			parameters.leafHashes,
+			nodes[branchesLen:branchesLen+values],
+		)
+	} else {
+		createLeaves(
+			parameters.data,
+			nodes[branchesLen:branchesLen+values],
+			hasher,
+			parameters.saltFunc,
+			parameters.salts,
+		)
+	}
+	data := parameters.data
+	salts := parameters.salts
+	var positions []uint64
+	var originalIndices []uint64
+	if parameters.sorted {
+		data, salts, positions, originalIndices = sortLeaves(nodes[branchesLen:branchesLen+values], data, salts)
 	}
+	// Pad the space left after the leaves.
+	padLeaves(nodes[branchesLen:], values, parameters.padding, hasher, parameters.hash)
 
 	// Branches.
-	createBranches(
-		nodes,
-		parameters.hash,
-		branchesLen,
-		parameters.sorted,
-	)
+	if arity == 2 {
+		createBranches(
+			nodes,
+			hasher,
+			branchesLen,
+			parameters.sorted,
+		)
+	} else {
+		createKaryBranches(
+			nodes,
+			hasher,
+			branchesLen,
+			arity,
+			parameters.sorted,
+		)
+	}
+	if parameters.countMixIn {
+		nodes[0] = mixInCount(hasher, nodes[1], uint64(values))
+	}
 
 	tree := &MerkleTree{
-		Salt:   parameters.salt,
-		Sorted: parameters.sorted,
-		Hash:   parameters.hash,
-		Nodes:  nodes,
-		Data:   parameters.data,
+		Salt:       parameters.salt,
+		SaltFunc:   parameters.saltFunc,
+		Salts:      salts,
+		Sorted:     parameters.sorted,
+		Hash:       parameters.hash,
+		Nodes:      nodes,
+		Data:       data,
+		Count:      uint64(values),
+		Positions:  positions,
+		Padding:    parameters.padding,
+		CountMixIn: parameters.countMixIn,
+		Arity:      parameters.arity,
+
+		originalIndices: originalIndices,
 	}
 
 	return tree, nil
@@ -246,39 +398,125 @@ func New(data [][]byte) (*MerkleTree, error) {
 }
 
 // Hashes the data slice, placing the result hashes into dest.
-// salt adds a salt to the hash using the index.
-// sorted sorts the leaves and data by the value of the leaf hash.
-func createLeaves(data [][]byte, dest [][]byte, hash HashType, salt, sorted bool) {
-	indexSalt := make([]byte, 4)
+// If the hasher is streaming the hashes are written in to the existing storage of dest.
+// saltFunc, if present, adds a salt to the hash using the index.
+// salts, if present, adds the per-value salt to the hash.
+func createLeaves(data [][]byte, dest [][]byte, hasher *hasher, saltFunc SaltFunc, salts [][]byte) {
 	for i := range data {
-		if salt {
-			binary.BigEndian.PutUint32(indexSalt, uint32(i))
-			dest[i] = hash.Hash(data[i], indexSalt)
+		if salts != nil {
+			dest[i] = hasher.hashLeaf(dest[i], data[i], salts[i])
 		} else {
-			dest[i] = hash.Hash(data[i])
+			dest[i] = hasher.hashLeaf(dest[i], data[i], saltFor(saltFunc, uint64(i)))
 		}
 	}
+}
+
+// Copies the leaf hashes in to dest, re-using the existing storage of dest if present.
+func copyLeaves(leafHashes [][]byte, dest [][]byte) {
+	for i := range leafHashes {
+		dest[i] = append(dest[i][:0], leafHashes[i]...)
+	}
+}
+
+// Sorts the leaves by value, returning copies of the data and salts in the same order as the leaves along with the position in
+// the tree of each original index and the original index of each position in the tree.  The supplied data and salts are not
+// altered.
+func sortLeaves(leaves [][]byte, data [][]byte, salts [][]byte) ([][]byte, [][]byte, []uint64, []uint64) {
+	sorter := hashSorter{
+		hashes: leaves,
+		order:  make([]uint64, len(leaves)),
+	}
+	for i := range sorter.order {
+		sorter.order[i] = uint64(i)
+	}
+	sort.Sort(sorter)
+
+	positions := make([]uint64, len(leaves))
+	for i, index := range sorter.order {
+		positions[index] = uint64(i)
+	}
 
-	if sorted {
-		sorter := hashSorter{
-			data:   data,
-			hashes: dest,
+	return reorder(data, sorter.order), reorder(salts, sorter.order), positions, sorter.order
+}
+
+// invertPositions returns the original index of each position in the tree, or an error if the positions are not a permutation.
+func invertPositions(positions []uint64) ([]uint64, error) {
+	originalIndices := make([]uint64, len(positions))
+	seen := make([]bool, len(positions))
+	for originalIndex, position := range positions {
+		if position >= uint64(len(positions)) || seen[position] {
+			return nil, errors.New("invalid positions")
 		}
-		sort.Sort(sorter)
+		seen[position] = true
+		originalIndices[position] = uint64(originalIndex)
 	}
+
+	return originalIndices, nil
+}
+
+// reorder returns a copy of values in the given order, or nil if values is nil.
+func reorder(values [][]byte, order []uint64) [][]byte {
+	if values == nil {
+		return nil
+	}
+	res := make([][]byte, len(values))
+	for i, index := range order {
+		res[i] = values[index]
+	}
+
+	return res
 }
 
 // Create the branch nodes from the existing leaf data.
-func createBranches(nodes [][]byte, hash HashType, leafOffset int, sorted bool) {
-	for leafIndex := leafOffset - 1; leafIndex > 0; leafIndex-- {
-		left := nodes[leafIndex*2]
-		right := nodes[leafIndex*2+1]
+// Each level of branches is hashed as a batch.
+// Leaves that are nil are empty, as per PaddingNone.
+// If the hasher is streaming the hashes are written in to the existing storage of nodes.
+func createBranches(nodes [][]byte, hasher *hasher, leafOffset int, sorted bool) {
+	lefts := make([][]byte, leafOffset/2)
+	rights := make([][]byte, leafOffset/2)
+	for levelOffset := leafOffset / 2; levelOffset > 0; levelOffset /= 2 {
+		// The branches for this level are at indices [levelOffset, levelOffset*2), with children at [levelOffset*2, levelOffset*4).
+		// Children are only missing from the end of the level in unbalanced trees, so only the leading branches are hashed.
+		hashed := 0
+		for ; hashed < levelOffset; hashed++ {
+			left := nodes[(levelOffset+hashed)*2]
+			right := nodes[(levelOffset+hashed)*2+1]
+			if right == nil {
+				break
+			}
 
-		if sorted && bytes.Compare(left, right) == 1 {
-			nodes[leafIndex] = hash.Hash(right, left)
-		} else {
-			nodes[leafIndex] = hash.Hash(left, right)
+			if sorted && bytes.Compare(left, right) == 1 {
+				lefts[hashed], rights[hashed] = right, left
+			} else {
+				lefts[hashed], rights[hashed] = left, right
+			}
+		}
+		hasher.hashBranches(nodes[levelOffset:levelOffset+hashed], lefts[:hashed], rights[:hashed])
+
+		// Branches without a right child take the value of their left child, if present.
+		for i := hashed; i < levelOffset; i++ {
+			left := nodes[(levelOffset+i)*2]
+			if left == nil {
+				nodes[levelOffset+i] = nil
+			} else {
+				nodes[levelOffset+i] = append(nodes[levelOffset+i][:0], left...)
+			}
+		}
+	}
+}
+
+// Create the branch nodes from the existing leaf data for trees with an arity greater than 2.
+// If the hasher is streaming the hashes are written in to the existing storage of nodes.
+func createKaryBranches(nodes [][]byte, hasher *hasher, leafOffset int, arity uint64, sorted bool) {
+	children := make([][]byte, arity)
+	// Children always have higher indices than their parents, so working backwards ensures that they are hashed first.
+	for i := uint64(leafOffset) - 1; i > 0; i-- {
+		first := firstChild(i, arity)
+		copy(children, nodes[first:first+arity])
+		if sorted {
+			sortHashes(children)
 		}
+		nodes[i] = hasher.hashChildren(nodes[i], children)
 	}
 }
 
@@ -296,12 +534,24 @@ func NewUsing(data [][]byte, hash HashType, salt bool) (*MerkleTree, error) {
 
 // Pollard returns the Merkle root plus branches to a certain height.  Height 0 will return just the root, height 1 the root plus
 // the two branches directly above it, height 2 the root, two branches directly above it and four branches directly above them, etc.
+// For trees with a higher arity each level has that many times the number of branches of the level below it.
+// If the tree mixes its count in to the root height 0 returns the root after the count has been mixed in, as per Root(), against
+// which proofs with a height of 0 are verified.
 func (t *MerkleTree) Pollard(height int) [][]byte {
-	return t.Nodes[1:int(math.Exp2(float64(height+1)))]
+	if t.CountMixIn && height == 0 {
+		return [][]byte{t.Nodes[0]}
+	}
+
+	return t.Nodes[1:levelStart(height+1, t.arity())]
 }
 
 // Root returns the Merkle root (hash of the root node) of the tree.
+// If the tree mixes its count in to the root this is the root after the count has been mixed in.
 func (t *MerkleTree) Root() []byte {
+	if t.CountMixIn {
+		return t.Nodes[0]
+	}
+
 	return t.Nodes[1]
 }
 
@@ -310,7 +560,65 @@ func (t *MerkleTree) GetSalt() bool {
 	return t.Salt
 }
 
+// saltFunc returns the salt function of the tree, or nil if the tree is not salted.
+// Trees that are salted without a salt function, for example those created before salt functions were available, use IndexSalt32.
+func (t *MerkleTree) saltFunc() SaltFunc {
+	if !t.Salt {
+		return nil
+	}
+	if t.SaltFunc == nil {
+		return IndexSalt32
+	}
+
+	return t.SaltFunc
+}
+
+// values returns the number of values in the tree.
+// Trees created before the count was recorded are assumed to contain their data.
+func (t *MerkleTree) values() uint64 {
+	if t.Count == 0 {
+		return uint64(len(t.Data))
+	}
+
+	return t.Count
+}
+
+// mixedInCount returns the count of the tree if it is mixed in to the root, otherwise 0.
+func (t *MerkleTree) mixedInCount() uint64 {
+	if !t.CountMixIn {
+		return 0
+	}
+
+	return t.values()
+}
+
+// leafSalt returns the salt for the value at the given index in the tree, or nil if the tree is not salted.
+// Values are salted before the tree is sorted, so index salts are generated from the index of the data as originally supplied.
+func (t *MerkleTree) leafSalt(index uint64) []byte {
+	if t.Salts != nil {
+		return t.Salts[index]
+	}
+
+	return saltFor(t.saltFunc(), t.originalIndex(index))
+}
+
+// originalIndex returns the index of the data as originally supplied for the value at the given index in the tree.
+// This is only different from the index in the tree if the tree is sorted.
+func (t *MerkleTree) originalIndex(index uint64) uint64 {
+	if index < uint64(len(t.originalIndices)) {
+		return t.originalIndices[index]
+	}
+	// Trees that are not built or decoded by this package do not have the inverse of their positions.
+	for originalIndex, position := range t.Positions {
+		if position == index {
+			return uint64(originalIndex)
+		}
+	}
+
+	return index
+}
+
 // String implements the stringer interface.
 func (t *MerkleTree) String() string {
-	return hex.EncodeToString(t.Nodes[1])
+	return hex.EncodeToString(t.Root())
 }
@@ -15,12 +15,14 @@ package merkletree
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
 	"github.com/wealdtech/go-merkletree/v2/keccak256"
 	"github.com/wealdtech/go-merkletree/v2/sha3"
@@ -318,7 +320,7 @@ var tests = []struct {
 			[]byte("FooBaz"),
 			[]byte("BarBaz"),
 		},
-		root:   _byteArray("b7c3dd02859b9fb386507ad8de3746b152bc3f9c8aa10fed9999c223f05cca7c"),
+		root:   _byteArray("110969bb5cb78267db588032385b52401accc112537b3f712849ed4c96dc6371"),
 		sorted: true,
 		dot:    "digraph MerkleTree {rankdir = TB;node [shape=rectangle margin=\"0.2,0.2\"];\"Qux\" [shape=oval];\"Qux\"->16;16 [label=\"003d…71bd\"];16->8;\"Foo\" [shape=oval];\"Foo\"->17;17 [label=\"195e…278a\"];16->17 [style=invisible arrowhead=none];17->8;\"FooBar\" [shape=oval];\"FooBar\"->18;18 [label=\"3007…265f\"];17->18 [style=invisible arrowhead=none];18->9;\"Bar\" [shape=oval];\"Bar\"->19;19 [label=\"65a0…1755\"];18->19 [style=invisible arrowhead=none];19->9;\"Baz\" [shape=oval];\"Baz\"->20;20 [label=\"6a08…3c41\"];19->20 [style=invisible arrowhead=none];20->10;\"Quux\" [shape=oval];\"Quux\"->21;21 [label=\"7781…1042\"];20->21 [style=invisible arrowhead=none];21->10;\"FooBaz\" [shape=oval];\"FooBaz\"->22;22 [label=\"9209…fb63\"];21->22 [style=invisible arrowhead=none];22->11;\"Quuz\" [shape=oval];\"Quuz\"->23;23 [label=\"ce1e…f6ea\"];22->23 [style=invisible arrowhead=none];23->11;\"BarBaz\" [shape=oval];\"BarBaz\"->24;24 [label=\"f86c…483b\"];23->24 [style=invisible arrowhead=none];24->12;25 [label=\"0000…0000\"];24->25 [style=invisible arrowhead=none];25->12;26 [label=\"0000…0000\"];25->26 [style=invisible arrowhead=none];26->13;27 [label=\"0000…0000\"];26->27 [style=invisible arrowhead=none];27->13;28 [label=\"0000…0000\"];27->28 [style=invisible arrowhead=none];28->14;29 [label=\"0000…0000\"];28->29 [style=invisible arrowhead=none];29->14;30 [label=\"0000…0000\"];29->30 [style=invisible arrowhead=none];30->15;31 [label=\"0000…0000\"];30->31 [style=invisible arrowhead=none];31->15;{rank=same;16;17;18;19;20;21;22;23;24;25;26;27;28;29;30;31};15 [label=\"070f…81e0\"];15->7;14 [label=\"070f…81e0\"];14->7;13 [label=\"070f…81e0\"];13->6;12 [label=\"eaa6…7b07\"];12->6;11 [label=\"f4e2…59b9\"];11->5;10 [label=\"1bea…1f21\"];10->5;9 [label=\"ff5a…afc1\"];9->4;8 [label=\"0fb6…8e6c\"];8->4;7 [label=\"53da…4530\"];7->3;6 [label=\"812c…f0f7\"];6->3;5 [label=\"e0c3…a1ad\"];5->2;4 [label=\"1e5e…1997\"];4->2;3 [label=\"4f01…3c5c\"];3->1;2 [label=\"4ab6…2203\"];2->1;1 [label=\"1109…6371\"];}",
 	},
@@ -329,9 +331,12 @@ func TestNew(t *testing.T) {
 		tree, err := NewTree(
 			WithData(test.data),
 			WithHashType(test.hashType),
+			WithSorted(test.sorted),
 		)
-		treeUsing, _ := NewUsing(test.data, test.hashType, false)
-		assert.Equal(t, tree, treeUsing, fmt.Sprintf("NewTree vs NewUsing does not match at test %d", i))
+		if !test.sorted {
+			treeUsing, _ := NewUsing(test.data, test.hashType, false)
+			assert.Equal(t, tree, treeUsing, fmt.Sprintf("NewTree vs NewUsing does not match at test %d", i))
+		}
 		if test.createErr != nil {
 			assert.Equal(t, test.createErr.Error(), err.Error(), fmt.Sprintf("expected error at test %d", i))
 		} else {
@@ -348,6 +353,7 @@ func TestString(t *testing.T) {
 			tree, err := NewTree(
 				WithData(test.data),
 				WithHashType(test.hashType),
+				WithSorted(test.sorted),
 			)
 			assert.Nil(t, err, fmt.Sprintf("failed to create tree at test %d", i))
 			assert.Equal(t, hex.EncodeToString(test.root), tree.String(), fmt.Sprintf("incorrect string representation at test %d", i))
@@ -370,3 +376,209 @@ func TestInvalidMultiProof(t *testing.T) {
 	assert.False(t, verified, "invalid params verified should be false")
 	assert.Error(t, err, "invalid params should error")
 }
+
+func TestLeafHashes(t *testing.T) {
+	for i, test := range tests {
+		if test.createErr != nil {
+			continue
+		}
+		for _, sorted := range []bool{false, true} {
+			dataTree, err := NewTree(
+				WithData(append([][]byte{}, test.data...)),
+				WithHashType(test.hashType),
+				WithSorted(sorted),
+			)
+			require.NoError(t, err, fmt.Sprintf("failed to create data tree at test %d", i))
+
+			leafHashes := make([][]byte, len(test.data))
+			for j := range test.data {
+				leafHashes[j] = test.hashType.Hash(test.data[j])
+			}
+			tree, err := NewTree(
+				WithLeafHashes(leafHashes),
+				WithHashType(test.hashType),
+				WithSorted(sorted),
+			)
+			require.NoError(t, err, fmt.Sprintf("failed to create tree at test %d", i))
+			assert.Nil(t, tree.Data, fmt.Sprintf("unexpected data at test %d", i))
+			assert.Equal(t, dataTree.Root(), tree.Root(), fmt.Sprintf("unexpected root at test %d", i))
+			assert.Equal(t, dataTree.Pollard(0), tree.Pollard(0), fmt.Sprintf("unexpected pollard at test %d", i))
+
+			if sorted {
+				// Proofs of sorted trees are verified with multiproofs.
+				continue
+			}
+			for j := range leafHashes {
+				proof, err := tree.GenerateProofWithIndex(uint64(j), 0)
+				require.NoError(t, err, fmt.Sprintf("failed to create proof at test %d index %d", i, j))
+				verified, err := VerifyProofUsingLeafHash(test.hashType.Hash(test.data[j]), proof, [][]byte{tree.Root()}, test.hashType)
+				require.NoError(t, err, fmt.Sprintf("failed to verify proof at test %d index %d", i, j))
+				assert.True(t, verified, fmt.Sprintf("failed to verify proof at test %d index %d", i, j))
+				verified, err = VerifyProofUsing(test.data[j], false, proof, [][]byte{tree.Root()}, test.hashType)
+				require.NoError(t, err, fmt.Sprintf("failed to verify proof at test %d index %d", i, j))
+				assert.True(t, verified, fmt.Sprintf("failed to verify proof at test %d index %d", i, j))
+			}
+
+			indices := make([]uint64, len(leafHashes))
+			for j := range indices {
+				indices[j] = uint64(j)
+			}
+			multiProof, err := tree.GenerateMultiProofWithIndices(indices)
+			require.NoError(t, err, fmt.Sprintf("failed to create multiproof at test %d", i))
+			verified, err := multiProof.VerifyLeafHashes(leafHashes, tree.Root())
+			require.NoError(t, err, fmt.Sprintf("failed to verify multiproof at test %d", i))
+			assert.True(t, verified, fmt.Sprintf("failed to verify multiproof at test %d", i))
+		}
+	}
+}
+
+func TestLeafHashesErrors(t *testing.T) {
+	leafHash := make([]byte, 32)
+
+	tests := []struct {
+		params []Parameter
+		err    string
+	}{
+		{
+			params: []Parameter{WithLeafHashes([][]byte{})},
+			err:    "problem with parameters: tree must have at least 1 leaf hash",
+		},
+		{
+			params: []Parameter{WithLeafHashes([][]byte{leafHash}), WithData([][]byte{[]byte("Foo")})},
+			err:    "problem with parameters: cannot use both data and leaf hashes",
+		},
+		{
+			params: []Parameter{WithLeafHashes([][]byte{leafHash}), WithSalt(true)},
+			err:    "problem with parameters: leaf hashes cannot be salted",
+		},
+		{
+			params: []Parameter{WithLeafHashes([][]byte{leafHash, leafHash[1:]})},
+			err:    "problem with parameters: leaf hash at index 1 has incorrect length",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := NewTree(test.params...)
+		assert.EqualError(t, err, test.err, fmt.Sprintf("failed at test %d", i))
+	}
+
+	tree, err := NewTree(WithLeafHashes([][]byte{leafHash, leafHash}))
+	require.NoError(t, err)
+	proof, err := tree.GenerateProofWithIndex(0, 0)
+	require.NoError(t, err)
+	_, err = VerifyProofUsingLeafHash(leafHash[1:], proof, [][]byte{tree.Root()}, tree.Hash)
+	assert.EqualError(t, err, "leaf hash has incorrect length")
+	_, err = tree.GenerateDisclosureWithIndex(0)
+	assert.EqualError(t, err, "tree does not contain data")
+	_, err = tree.GenerateProof(leafHash[1:], 0)
+	assert.EqualError(t, err, "data not found")
+	assert.Contains(t, tree.DOT(nil, nil), "digraph MerkleTree")
+}
+
+func TestSortedPositions(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Foo"), []byte("Qux")}
+	original := append([][]byte{}, data...)
+
+	tree, err := NewTree(
+		WithData(data),
+		WithHashType(keccak256.New()),
+		WithSorted(true),
+	)
+	require.NoError(t, err)
+
+	// The supplied data is not reordered.
+	require.Equal(t, original, data)
+	require.Len(t, tree.Positions, len(data))
+
+	for i := range data {
+		index, err := tree.TreeIndex(uint64(i))
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.Equal(t, data[i], tree.Data[index], fmt.Sprintf("failed at index %d", i))
+
+		proof, err := tree.GenerateProofWithOriginalIndex(uint64(i), 0)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.Equal(t, index, proof.Index, fmt.Sprintf("failed at index %d", i))
+	}
+
+	// Equal values retain their original order.
+	first, err := tree.TreeIndex(0)
+	require.NoError(t, err)
+	second, err := tree.TreeIndex(3)
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+
+	multiProof, err := tree.GenerateMultiProofWithOriginalIndices([]uint64{1, 4})
+	require.NoError(t, err)
+	verified, err := multiProof.Verify([][]byte{data[1], data[4]}, tree.Root())
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	_, err = tree.TreeIndex(5)
+	assert.EqualError(t, err, "index out of range")
+	_, err = tree.GenerateMultiProofWithOriginalIndices([]uint64{5})
+	assert.EqualError(t, err, "index out of range")
+
+	// Unsorted trees have the same indices.
+	unsortedTree, err := NewTree(WithData(data), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+	assert.Nil(t, unsortedTree.Positions)
+	index, err := unsortedTree.TreeIndex(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), index)
+}
+
+func TestSortedSalted(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux")}
+
+	tree, err := NewTree(WithData(data), WithSalt(true), WithSorted(true))
+	require.NoError(t, err)
+
+	// Values are salted by their original index, which verifiers cannot obtain from proofs.
+	_, err = tree.GenerateProofWithOriginalIndex(0, 0)
+	assert.EqualError(t, err, "proofs are not supported for sorted trees salted by index")
+	_, err = tree.GenerateProof(data[0], 0)
+	assert.EqualError(t, err, "proofs are not supported for sorted trees salted by index")
+	_, err = tree.GenerateMultiProofWithOriginalIndices([]uint64{0, 1})
+	assert.EqualError(t, err, "multiproofs are not supported for sorted trees salted by index")
+
+	// The original indices are retained when exported.
+	exported, err := json.Marshal(tree)
+	require.NoError(t, err)
+	var newTree MerkleTree
+	require.NoError(t, json.Unmarshal(exported, &newTree))
+	for i := range data {
+		index, err := tree.TreeIndex(uint64(i))
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.Equal(t, uint64(i), tree.originalIndex(index), fmt.Sprintf("failed at index %d", i))
+		assert.Equal(t, tree.leafSalt(index), newTree.leafSalt(index), fmt.Sprintf("failed at index %d", i))
+	}
+
+	newTree.Positions = []uint64{0, 0, 1, 2}
+	exported, err = json.Marshal(&newTree)
+	require.NoError(t, err)
+	assert.EqualError(t, json.Unmarshal(exported, &MerkleTree{}), "invalid positions")
+}
+
+func TestSortedDeterministic(t *testing.T) {
+	hashType := keccak256.New()
+	leafHashes := [][]byte{hashType.Hash([]byte("Foo")), hashType.Hash([]byte("Bar")), hashType.Hash([]byte("Foo"))}
+
+	tree, err := NewTree(
+		WithLeafHashes(leafHashes),
+		WithHashType(hashType),
+		WithSorted(true),
+	)
+	require.NoError(t, err)
+	positions := tree.Positions
+	assert.Less(t, positions[0], positions[2])
+
+	for i := 0; i < 10; i++ {
+		tree, err := NewTree(
+			WithLeafHashes(leafHashes),
+			WithHashType(hashType),
+			WithSorted(true),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, positions, tree.Positions)
+	}
+}
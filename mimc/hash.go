@@ -0,0 +1,108 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mimc provides hashing using the MiMC-7 system.
+//
+// Each input is treated as a single field element, and inputs are hashed together with MultiMiMC7 using a key of 0.  This
+// matches the way that circom and iden3 circuits hash leaves and branches.
+package mimc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/mimc7"
+	"github.com/iden3/go-iden3-crypto/utils"
+)
+
+const _hashlength = 32
+
+// MiMC7 is the MiMC-7 hashing method.
+type MiMC7 struct{}
+
+// New creates a new MiMC-7 hashing method.
+func New() *MiMC7 {
+	return &MiMC7{}
+}
+
+// HashLength returns the length of hashes generated by Hash() in bytes.
+func (*MiMC7) HashLength() int {
+	return _hashlength
+}
+
+// HashName returns the name of this hash.
+func (*MiMC7) HashName() string {
+	return "mimc7"
+}
+
+// Hash generates a MiMC-7 hash from input byte arrays, each of which is treated as a field element.
+// This returns nil if any input is not a valid field element; use HashFieldElements() to obtain the error.
+func (h *MiMC7) Hash(data ...[]byte) []byte {
+	hash, err := h.HashFieldElements(data...)
+	if err != nil {
+		return nil
+	}
+
+	return hash
+}
+
+// HashFieldElements generates a MiMC-7 hash of the inputs, each of which is treated as a single field element.
+// The result is a 32-byte big-endian field element.
+func (*MiMC7) HashFieldElements(data ...[]byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no data supplied")
+	}
+
+	elements := make([]*big.Int, len(data))
+	for i := range data {
+		element, err := FieldElement(data[i])
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element
+	}
+
+	hash, err := mimc7.Hash(elements, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.FillBytes(make([]byte, _hashlength)), nil
+}
+
+// CheckData returns an error if the data is not a valid field element.
+func (*MiMC7) CheckData(data []byte) error {
+	_, err := FieldElement(data)
+
+	return err
+}
+
+// FieldElement decodes big-endian data as a field element.
+func (*MiMC7) FieldElement(data []byte) (*big.Int, error) {
+	return FieldElement(data)
+}
+
+// FieldElement decodes big-endian data as a field element.
+// An error is returned if the data is longer than 32 bytes or its value is not inside the field.
+func FieldElement(data []byte) (*big.Int, error) {
+	if len(data) > _hashlength {
+		return nil, fmt.Errorf("field element must be at most %d bytes", _hashlength)
+	}
+	element := new(big.Int).SetBytes(data)
+	if !utils.CheckBigIntInField(element) {
+		return nil, errors.New("value not inside field")
+	}
+
+	return element, nil
+}
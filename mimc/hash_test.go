@@ -0,0 +1,90 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mimc_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2/mimc"
+)
+
+// _byteArray is a helper to turn a string in to a byte array
+func _byteArray(input string) []byte {
+	x, err := hex.DecodeString(input)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func TestHash(t *testing.T) {
+	// Test vectors match those of the circomlib MultiMiMC7 circuit with 91 rounds and a key of 0.
+	tests := []struct {
+		data   [][]byte
+		output []byte
+		err    string
+	}{
+		{ // 0
+			data:   [][]byte{{12}},
+			output: _byteArray("237c92644dbddb86d8a259e0e923aaab65a93f1ec5758b8799988894ac0958fd"),
+		},
+		{ // 1
+			data:   [][]byte{{78}, {41}},
+			output: _byteArray("067f3202335ea256ae6e6aadcd2d5f7f4b06a00b2d1e0de903980d5ab552dc70"),
+		},
+		{ // 2
+			data:   [][]byte{{12}, {45}},
+			output: _byteArray("15ff7fe9793346a17c3150804bcb36d161c8662b110c50f55ccb7113948d8879"),
+		},
+		{ // 3
+			data:   [][]byte{{12}, {45}, {78}, {41}},
+			output: _byteArray("284bc1f34f335933a23a433b6ff3ee179d682cd5e5e2fcdd2d964afa85104beb"),
+		},
+		{ // 4
+			data: [][]byte{
+				_byteArray("000000000000000000000000000000000000000000000000000000000000000c"),
+				_byteArray("000000000000000000000000000000000000000000000000000000000000002d"),
+			},
+			output: _byteArray("15ff7fe9793346a17c3150804bcb36d161c8662b110c50f55ccb7113948d8879"),
+		},
+		{ // 5
+			data: [][]byte{_byteArray("30644e72e131a029b85045b68181585d2833e84879b9709143e1f593f0000001")},
+			err:  "value not inside field",
+		},
+		{ // 6
+			data: [][]byte{make([]byte, 33)},
+			err:  "field element must be at most 32 bytes",
+		},
+		{ // 7
+			err: "no data supplied",
+		},
+	}
+
+	hash := mimc.New()
+	assert.Equal(t, "mimc7", hash.HashName())
+	for i, test := range tests {
+		output, err := hash.HashFieldElements(test.data...)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, fmt.Sprintf("incorrect error at test %d", i))
+			assert.Nil(t, hash.Hash(test.data...), fmt.Sprintf("unexpected output at test %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error at test %d", i))
+			assert.Equal(t, test.output, output, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, test.output, hash.Hash(test.data...), fmt.Sprintf("failed at test %d", i))
+		}
+	}
+}
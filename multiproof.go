@@ -15,7 +15,7 @@ package merkletree
 
 import (
 	"bytes"
-	"encoding/binary"
+	"fmt"
 
 	"github.com/pkg/errors"
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
@@ -23,13 +23,20 @@ import (
 
 // MultiProof is a single structure containing multiple proofs of a Merkle tree.
 type MultiProof struct {
-	// Values is the number of values in the Merkle tree.
+	// Values is the index of the first leaf in the nodes of the Merkle tree.  For binary trees this is also the number of
+	// leaves.
 	Values uint64
 	// Hashes are indexed hashes of values that cannot be calculated from the index data
 	Hashes map[uint64][]byte
 	// Indices are the indices of the data that can be proved with the hashes
 	Indices []uint64
-	salt    bool
+	// Count is the number of values in the Merkle tree if it is mixed in to the root, otherwise 0.
+	Count uint64
+	// Arity is the number of children of each branch in the Merkle tree, or 0 if the tree is binary.
+	Arity int
+	salt  bool
+	// saltFunc generates the salt for each value if salt is true
+	saltFunc SaltFunc
 	// if sorted is true, the hash values are sorted before hashing branch nodes
 	sorted bool
 	hash   HashType
@@ -43,55 +50,233 @@ func NewMultiProof(params ...Parameter) (*MultiProof, error) {
 	}
 
 	return &MultiProof{
-		Values:  parameters.values,
-		Hashes:  parameters.hashes,
-		Indices: parameters.indices,
-		salt:    parameters.salt,
-		sorted:  parameters.sorted,
-		hash:    parameters.hash,
+		Values:   parameters.values,
+		Hashes:   parameters.hashes,
+		Indices:  parameters.indices,
+		Count:    parameters.count,
+		Arity:    parameters.arity,
+		salt:     parameters.salt,
+		saltFunc: parameters.saltFunc,
+		sorted:   parameters.sorted,
+		hash:     parameters.hash,
 	}, nil
 }
 
 // Verify verifies a multiproof.
 func (p *MultiProof) Verify(data [][]byte, root []byte) (bool, error) {
+	for i, index := range p.Indices {
+		if err := checkLeaf(p.hash, data[i], saltFor(p.saltFunc, index)); err != nil {
+			return false, err
+		}
+	}
+	if err := p.checkHashes(); err != nil {
+		return false, err
+	}
+
+	hasher := newHasher(p.hash)
+	hashes := p.copyHashes()
+
 	// Step 1 create hashes for all values.
-	var proofHash []byte
-	indexSalt := make([]byte, 4)
 	for i, index := range p.Indices {
-		if p.salt {
-			binary.BigEndian.PutUint32(indexSalt, uint32(index))
-			proofHash = p.hash.Hash(data[i], indexSalt)
-		} else {
-			proofHash = p.hash.Hash(data[i])
+		hashes[index+p.Values] = hasher.hashLeaf(nil, data[i], saltFor(p.saltFunc, index))
+	}
+
+	return p.verifyBranches(hasher, hashes, root), nil
+}
+
+// VerifyLeafHashes verifies a multiproof using the leaf hashes of the data rather than the data itself.
+// This allows verification of multiproofs without access to the data, for example with trees created using WithLeafHashes().
+func (p *MultiProof) VerifyLeafHashes(leafHashes [][]byte, root []byte) (bool, error) {
+	if len(leafHashes) != len(p.Indices) {
+		return false, errors.New("leaf hashes and indices must be the same length")
+	}
+	for i := range leafHashes {
+		if len(leafHashes[i]) != p.hash.HashLength() {
+			return false, fmt.Errorf("leaf hash at index %d has incorrect length", i)
+		}
+		if err := checkData(p.hash, leafHashes[i]); err != nil {
+			return false, fmt.Errorf("invalid leaf hash at index %d: %w", i, err)
 		}
-		p.Hashes[index+p.Values] = proofHash
+	}
+	if err := p.checkHashes(); err != nil {
+		return false, err
+	}
+
+	hashes := p.copyHashes()
+
+	// Step 1 add the leaf hashes for all values.
+	for i, index := range p.Indices {
+		hashes[index+p.Values] = leafHashes[i]
+	}
+
+	return p.verifyBranches(newHasher(p.hash), hashes, root), nil
+}
+
+// ComputeUpdatedRoot computes the root of the tree after the values at the indices of the multiproof are replaced with the new
+// data.  There must be a piece of data for each index, in the same order as the indices; values that are unchanged should be
+// supplied as-is.  This allows a verifier holding only a root and a multiproof to follow changes to the tree; the existing data
+// should first be verified against the existing root with Verify().
+//
+// Roots of sorted trees cannot be updated, as changing a value can change the order of the leaves.  If the tree is padded with
+// PaddingDuplicateLast its last value cannot be changed, as the padding leaves would also change.
+func (p *MultiProof) ComputeUpdatedRoot(newData [][]byte) ([]byte, error) {
+	if p.sorted {
+		return nil, errors.New("roots of sorted trees cannot be updated")
+	}
+	if len(newData) != len(p.Indices) {
+		return nil, errors.New("data and indices must be the same length")
+	}
+	for i, index := range p.Indices {
+		if err := checkLeaf(p.hash, newData[i], saltFor(p.saltFunc, index)); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.checkHashes(); err != nil {
+		return nil, err
+	}
+	if !p.indicesInCount() {
+		return nil, errors.New("index out of range")
+	}
+
+	hasher := newHasher(p.hash)
+	hashes := p.copyHashes()
+	for i, index := range p.Indices {
+		hashes[index+p.Values] = hasher.hashLeaf(nil, newData[i], saltFor(p.saltFunc, index))
+	}
+
+	root := p.calculateRoot(hasher, hashes)
+	if root == nil {
+		return nil, errors.New("invalid proof")
+	}
+
+	return root, nil
+}
+
+// copyHashes returns a copy of the hashes of the multiproof, to which the hashes calculated during verification are added.
+func (p *MultiProof) copyHashes() map[uint64][]byte {
+	hashes := make(map[uint64][]byte, len(p.Hashes))
+	for index, hash := range p.Hashes {
+		hashes[index] = hash
 	}
 
+	return hashes
+}
+
+// checkHashes checks that the hashes of the multiproof can be hashed by its hash type.
+func (p *MultiProof) checkHashes() error {
+	for index, hash := range p.Hashes {
+		if err := checkData(p.hash, hash); err != nil {
+			return fmt.Errorf("invalid hash at index %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+// indicesInCount returns true if the multiproof has no count, or all indices are inside it.
+func (p *MultiProof) indicesInCount() bool {
+	if p.Count != 0 {
+		for _, index := range p.Indices {
+			if index >= p.Count {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// verifyBranches calculates the branches of the multiproof from the hashes present, and checks the result against the root.
+// If the multiproof has a count it is mixed in to the result, and all indices must be inside it.
+func (p *MultiProof) verifyBranches(hasher *hasher, hashes map[uint64][]byte, root []byte) bool {
+	if !p.indicesInCount() {
+		return false
+	}
+
+	calculated := p.calculateRoot(hasher, hashes)
+
+	return calculated != nil && bytes.Equal(calculated, root)
+}
+
+// calculateRoot calculates the branches of the multiproof from the hashes present, returning the root or nil if it cannot be
+// calculated.  If the multiproof has a count it is mixed in to the result.
+func (p *MultiProof) calculateRoot(hasher *hasher, hashes map[uint64][]byte) []byte {
 	// Step 2 calculate values up the tree.
+	if arity := arityOf(p.Arity); arity != 2 {
+		p.calculateKaryBranches(hasher, hashes, arity)
+	} else {
+		p.calculateBinaryBranches(hasher, hashes)
+	}
+
+	root, exists := hashes[1]
+	if !exists || root == nil {
+		return nil
+	}
+	if p.Count != 0 {
+		return mixInCount(hasher, root, p.Count)
+	}
+
+	return root
+}
+
+// calculateBinaryBranches calculates the branches of a binary tree from the hashes present.
+func (p *MultiProof) calculateBinaryBranches(hasher *hasher, hashes map[uint64][]byte) {
 	for i := p.Values - 1; i > 0; i-- {
-		_, exists := p.Hashes[i]
+		_, exists := hashes[i]
 		if exists {
 			continue
 		}
 
-		child1, exists := p.Hashes[i*2]
+		child1, exists := hashes[i*2]
 		if !exists {
 			continue
 		}
 
-		child2, exists := p.Hashes[i*2+1]
+		child2, exists := hashes[i*2+1]
 		if !exists {
 			continue
 		}
 
-		if p.sorted && bytes.Compare(child1, child2) == 1 {
-			p.Hashes[i] = p.hash.Hash(child2, child1)
-		} else {
-			p.Hashes[i] = p.hash.Hash(child1, child2)
+		switch {
+		case child1 == nil:
+			// Only right subtrees can be empty.
+			continue
+		case child2 == nil:
+			// The right subtree is empty in an unbalanced tree, so the branch has the same value as its left child.
+			hashes[i] = child1
+		case p.sorted && bytes.Compare(child1, child2) == 1:
+			hashes[i] = hasher.hashBranch(nil, child2, child1)
+		default:
+			hashes[i] = hasher.hashBranch(nil, child1, child2)
 		}
 	}
+}
 
-	return bytes.Equal(p.Hashes[1], root), nil
+// calculateKaryBranches calculates the branches of a tree with an arity greater than 2 from the hashes present.
+func (p *MultiProof) calculateKaryBranches(hasher *hasher, hashes map[uint64][]byte, arity uint64) {
+	children := make([][]byte, arity)
+	for i := p.Values - 1; i > 0; i-- {
+		_, exists := hashes[i]
+		if exists {
+			continue
+		}
+
+		first := firstChild(i, arity)
+		for j := range children {
+			children[j], exists = hashes[first+uint64(j)]
+			if !exists {
+				break
+			}
+		}
+		if !exists {
+			continue
+		}
+
+		if p.sorted {
+			sortHashes(children)
+		}
+		hashes[i] = hasher.hashChildren(nil, children)
+	}
 }
 
 // VerifyMultiProof verifies multiple Merkle tree proofs for pieces of data using the default hash type.
@@ -121,6 +306,8 @@ func VerifyMultiProofUsing(data [][]byte, salt bool, proof *MultiProof, root []b
 		WithIndices(proof.Indices),
 		WithHashes(proof.Hashes),
 		WithValues(proof.Values),
+		WithCount(proof.Count),
+		WithArity(proof.Arity),
 	)
 	if err != nil {
 		return false, err
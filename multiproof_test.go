@@ -44,7 +44,7 @@ func TestMultiProofWithIndices(t *testing.T) {
 				for k := 0; k < len(test.data); k++ {
 					if (j>>k)&1 == 1 {
 						indices = append(indices, uint64(k))
-						items = append(items, test.data[k])
+						items = append(items, tree.Data[k])
 					}
 				}
 				proof, err = tree.GenerateMultiProofWithIndices(indices)
@@ -231,3 +231,118 @@ func TestSavings(t *testing.T) {
 	t.Log(fmt.Sprintf("Multiproof size over simple proofs:\t%d/%d\t=>\t%2.2f%% saving", multiProofSize, proofSize, float32(100)-float32(multiProofSize*100)/float32(proofSize)))
 	t.Log(fmt.Sprintf("Multiproof size over pollard:\t%d/%d\t=>\t%2.2f%% saving", multiProofSize, pollardSize, float32(100)-float32(multiProofSize*100)/float32(pollardSize)))
 }
+
+func TestMultiProofComputeUpdatedRoot(t *testing.T) {
+	tests := []struct {
+		values  int
+		indices []uint64
+		changes []int
+		params  []Parameter
+	}{
+		{
+			values:  1,
+			indices: []uint64{0},
+			changes: []int{0},
+		},
+		{
+			values:  10,
+			indices: []uint64{1, 2, 7},
+			changes: []int{1, 7},
+			params:  []Parameter{WithSalt(true)},
+		},
+		{
+			values:  10,
+			indices: []uint64{0, 9},
+			changes: []int{0, 9},
+			params:  []Parameter{WithPadding(PaddingEmptyHash), WithCountMixIn(true)},
+		},
+		{
+			values:  11,
+			indices: []uint64{3, 10},
+			changes: []int{10},
+			params:  []Parameter{WithPadding(PaddingNone)},
+		},
+		{
+			values:  20,
+			indices: []uint64{0, 5, 19},
+			changes: []int{5, 19},
+			params:  []Parameter{WithArity(4)},
+		},
+	}
+
+	for i, test := range tests {
+		data := diffData(test.values)
+		tree, err := NewTree(append([]Parameter{WithData(data)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		updatedData := diffData(test.values, test.changes...)
+		updated, err := NewTree(append([]Parameter{WithData(updatedData)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		proof, err := tree.GenerateMultiProofWithIndices(test.indices)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		oldData := make([][]byte, len(test.indices))
+		newData := make([][]byte, len(test.indices))
+		for j, index := range test.indices {
+			oldData[j] = data[index]
+			newData[j] = updatedData[index]
+		}
+
+		verified, err := proof.Verify(oldData, tree.Root())
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at test %d", i))
+		root, err := proof.ComputeUpdatedRoot(newData)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, updated.Root(), root, fmt.Sprintf("failed at test %d", i))
+
+		// The proof is unchanged by the update, so verifies the new data against the new root and not the old.
+		verified, err = proof.Verify(newData, root)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at test %d", i))
+		verified, err = proof.Verify(newData, tree.Root())
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.False(t, verified, fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestMultiProofComputeUpdatedRootErrors(t *testing.T) {
+	tree, err := NewTree(WithData(diffData(4)))
+	require.NoError(t, err)
+	proof, err := tree.GenerateMultiProofWithIndices([]uint64{1, 2})
+	require.NoError(t, err)
+
+	_, err = proof.ComputeUpdatedRoot([][]byte{[]byte("new")})
+	assert.EqualError(t, err, "data and indices must be the same length")
+
+	sorted, err := NewTree(WithData(diffData(4)), WithSorted(true))
+	require.NoError(t, err)
+	sortedProof, err := sorted.GenerateMultiProofWithIndices([]uint64{1})
+	require.NoError(t, err)
+	_, err = sortedProof.ComputeUpdatedRoot([][]byte{[]byte("new")})
+	assert.EqualError(t, err, "roots of sorted trees cannot be updated")
+
+	counted, err := NewMultiProof(WithValues(4), WithIndices([]uint64{5}), WithCount(4))
+	require.NoError(t, err)
+	_, err = counted.ComputeUpdatedRoot([][]byte{[]byte("new")})
+	assert.EqualError(t, err, "index out of range")
+
+	incomplete, err := NewMultiProof(WithValues(4), WithIndices([]uint64{1}), WithHashes(map[uint64][]byte{}))
+	require.NoError(t, err)
+	_, err = incomplete.ComputeUpdatedRoot([][]byte{[]byte("new")})
+	assert.EqualError(t, err, "invalid proof")
+}
+
+func TestMultiProofVerifyRepeated(t *testing.T) {
+	tree, err := NewTree(WithData(diffData(8)))
+	require.NoError(t, err)
+	proof, err := tree.GenerateMultiProofWithIndices([]uint64{1, 5})
+	require.NoError(t, err)
+
+	verified, err := proof.Verify([][]byte{[]byte("value 1"), []byte("value 5")}, tree.Root())
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// Hashes calculated by an earlier verification must not be used by a later one.
+	verified, err = proof.Verify([][]byte{[]byte("bad"), []byte("bad")}, tree.Root())
+	require.NoError(t, err)
+	assert.False(t, verified)
+}
@@ -0,0 +1,114 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Padding is the strategy used to fill the leaves of a tree beyond its values, up to the next power of 2.
+type Padding int
+
+const (
+	// PaddingZeroHash pads with leaves of all zeros.  This is the default.
+	PaddingZeroHash Padding = iota
+	// PaddingEmptyHash pads with leaves that are the hash of empty input.
+	PaddingEmptyHash
+	// PaddingDuplicateLast pads with copies of the last leaf.
+	PaddingDuplicateLast
+	// PaddingNone does not pad, creating an unbalanced tree.  A branch with an empty right subtree takes the hash of its left
+	// child, and the proof hash for the empty subtree is nil.
+	PaddingNone
+)
+
+var paddingNames = map[Padding]string{
+	PaddingZeroHash:      "zero-hash",
+	PaddingEmptyHash:     "empty-hash",
+	PaddingDuplicateLast: "duplicate-last",
+	PaddingNone:          "none",
+}
+
+// String implements the stringer interface.
+func (p Padding) String() string {
+	name, exists := paddingNames[p]
+	if !exists {
+		return fmt.Sprintf("unknown (%d)", int(p))
+	}
+
+	return name
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Padding) MarshalText() ([]byte, error) {
+	name, exists := paddingNames[p]
+	if !exists {
+		return nil, errors.New("unknown padding")
+	}
+
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Padding) UnmarshalText(input []byte) error {
+	for padding, name := range paddingNames {
+		if name == string(input) {
+			*p = padding
+
+			return nil
+		}
+	}
+
+	return errors.New("cannot parse padding")
+}
+
+// padLeaves fills the leaves after the values with the padding.
+// If the hasher is streaming the padding is written in to the existing storage of the leaves.
+func padLeaves(leaves [][]byte, values int, padding Padding, hasher *hasher, hashType HashType) {
+	if values == len(leaves) {
+		return
+	}
+
+	if padding == PaddingNone {
+		for i := values; i < len(leaves); i++ {
+			leaves[i] = nil
+		}
+
+		return
+	}
+
+	pad := paddingLeaf(padding, hashType, leaves[values-1])
+	for i := values; i < len(leaves); i++ {
+		if hasher.streaming() {
+			leaves[i] = append(leaves[i][:0], pad...)
+		} else {
+			leaves[i] = append(make([]byte, 0, len(pad)), pad...)
+		}
+	}
+}
+
+// paddingLeaf returns the leaf used to pad a tree with the given last leaf, or nil if the tree is not padded.
+func paddingLeaf(padding Padding, hashType HashType, last []byte) []byte {
+	switch padding {
+	case PaddingNone:
+		return nil
+	case PaddingEmptyHash:
+		return hashType.Hash([]byte{})
+	case PaddingDuplicateLast:
+		return last
+	default:
+		return make([]byte, hashType.HashLength())
+	}
+}
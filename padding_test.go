@@ -0,0 +1,162 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestPaddingRoots(t *testing.T) {
+	hashType := keccak256.New()
+	a := hashType.Hash([]byte("Foo"))
+	b := hashType.Hash([]byte("Bar"))
+	c := hashType.Hash([]byte("Baz"))
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+
+	tests := []struct {
+		padding Padding
+		root    []byte
+	}{
+		{
+			padding: PaddingZeroHash,
+			root:    hashType.Hash(hashType.Hash(a, b), hashType.Hash(c, make([]byte, 32))),
+		},
+		{
+			padding: PaddingEmptyHash,
+			root:    hashType.Hash(hashType.Hash(a, b), hashType.Hash(c, hashType.Hash([]byte{}))),
+		},
+		{
+			padding: PaddingDuplicateLast,
+			root:    hashType.Hash(hashType.Hash(a, b), hashType.Hash(c, c)),
+		},
+		{
+			padding: PaddingNone,
+			root:    hashType.Hash(hashType.Hash(a, b), c),
+		},
+	}
+
+	for i, test := range tests {
+		tree, err := NewTree(WithData(data), WithHashType(hashType), WithPadding(test.padding))
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, test.root, tree.Root(), fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestPaddingProofs(t *testing.T) {
+	for _, hashType := range []HashType{keccak256.New(), blake2b.New(), nonStreamingHashType{keccak256.New()}} {
+		for _, padding := range []Padding{PaddingZeroHash, PaddingEmptyHash, PaddingDuplicateLast, PaddingNone} {
+			for _, sorted := range []bool{false, true} {
+				for values := 1; values <= 9; values++ {
+					name := fmt.Sprintf("%s/padding=%s/sorted=%t/values=%d", hashType.HashName(), padding, sorted, values)
+					data := make([][]byte, values)
+					for i := range data {
+						data[i] = []byte(fmt.Sprintf("value %d", i))
+					}
+					tree, err := NewTree(
+						WithData(data),
+						WithHashType(hashType),
+						WithPadding(padding),
+						WithSorted(sorted),
+					)
+					require.NoError(t, err, name)
+
+					if !sorted {
+						for i := range tree.Data {
+							proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+							require.NoError(t, err, name)
+							verified, err := VerifyProofUsing(tree.Data[i], false, proof, [][]byte{tree.Root()}, hashType)
+							require.NoError(t, err, name)
+							assert.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+
+							if values > 2 {
+								proof, err = tree.GenerateProofWithIndex(uint64(i), 1)
+								require.NoError(t, err, name)
+								verified, err = VerifyProofUsing(tree.Data[i], false, proof, tree.Pollard(1), hashType)
+								require.NoError(t, err, name)
+								assert.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+								assert.True(t, VerifyPollardUsing(tree.Pollard(1), hashType), name)
+							}
+						}
+					}
+
+					for i := range tree.Data {
+						for j := i; j < len(tree.Data); j++ {
+							multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{uint64(i), uint64(j)})
+							require.NoError(t, err, name)
+							verified, err := multiProof.Verify([][]byte{tree.Data[i], tree.Data[j]}, tree.Root())
+							require.NoError(t, err, name)
+							assert.True(t, verified, fmt.Sprintf("%s/indices=%d,%d", name, i, j))
+						}
+					}
+
+					assert.NotPanics(t, func() { tree.DOT(nil, nil) }, name)
+				}
+			}
+		}
+	}
+}
+
+func TestPaddingDOT(t *testing.T) {
+	tree, err := NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+		WithHashType(keccak256.New()),
+		WithPadding(PaddingNone),
+	)
+	require.NoError(t, err)
+	dot := tree.DOT(new(StringFormatter), nil)
+	assert.NotContains(t, dot, "7 [")
+	assert.Contains(t, dot, "6->3;")
+
+	tree, err = NewTree(
+		WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+		WithHashType(keccak256.New()),
+		WithPadding(PaddingDuplicateLast),
+	)
+	require.NoError(t, err)
+	dot = tree.DOT(new(StringFormatter), new(HexFormatter))
+	assert.Contains(t, dot, fmt.Sprintf("7 [label=\"%x\"];", tree.Nodes[6]))
+}
+
+func TestPaddingEncoding(t *testing.T) {
+	for _, padding := range []Padding{PaddingZeroHash, PaddingEmptyHash, PaddingDuplicateLast, PaddingNone} {
+		tree, err := NewTree(
+			WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+			WithPadding(padding),
+		)
+		require.NoError(t, err)
+
+		exported, err := json.Marshal(tree)
+		require.NoError(t, err)
+		if padding != PaddingZeroHash {
+			assert.Contains(t, string(exported), fmt.Sprintf(`"padding":"%s"`, padding))
+		}
+
+		var newTree MerkleTree
+		require.NoError(t, json.Unmarshal(exported, &newTree))
+		assert.Equal(t, padding, newTree.Padding)
+		assert.Equal(t, tree.Nodes, newTree.Nodes)
+	}
+
+	var padding Padding
+	assert.EqualError(t, padding.UnmarshalText([]byte("unknown")), "cannot parse padding")
+	_, err := NewTree(WithData([][]byte{[]byte("Foo")}), WithPadding(Padding(99)))
+	assert.EqualError(t, err, "problem with parameters: unknown padding")
+}
@@ -14,19 +14,33 @@
 package merkletree
 
 import (
+	"crypto/rand"
 	"errors"
+	"fmt"
 
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
 )
 
 type parameters struct {
-	data    [][]byte
-	values  uint64
-	hashes  map[uint64][]byte
-	indices []uint64
-	salt    bool
-	sorted  bool
-	hash    HashType
+	data       [][]byte
+	dataFunc   DataFunc
+	dataCount  uint64
+	leafHashes [][]byte
+	values     uint64
+	hashes     map[uint64][]byte
+	indices    []uint64
+	salt       bool
+	saltFunc   SaltFunc
+	salts      [][]byte
+	random     bool
+	sorted     bool
+	padding    Padding
+	countMixIn bool
+	count      uint64
+	arity      int
+	width      uint64
+	spillDir   string
+	hash       HashType
 }
 
 // Parameter is the interface for service parameters.
@@ -47,7 +61,23 @@ func WithData(data [][]byte) Parameter {
 	})
 }
 
-// WithValues sets the values for the merkle proof.
+// WithDataFunc sets a function to fetch the data for a lazy merkle tree, in place of data.  Count is the number of values.
+func WithDataFunc(count uint64, dataFunc DataFunc) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.dataCount = count
+		p.dataFunc = dataFunc
+	})
+}
+
+// WithLeafHashes sets the leaf hashes for the merkle tree, in place of data.
+// Each leaf hash must be the length of the hashes generated by the tree's hash type.
+func WithLeafHashes(leafHashes [][]byte) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.leafHashes = leafHashes
+	})
+}
+
+// WithValues sets the values for the merkle proof, being the index of the first leaf in the nodes of the tree.
 func WithValues(values uint64) Parameter {
 	return parameterFunc(func(p *parameters) {
 		p.values = values
@@ -75,13 +105,81 @@ func WithSalt(salt bool) Parameter {
 	})
 }
 
+// WithSaltFunc sets the function used to salt values in the merkle tree or proof.
+// Setting a salt function enables salting.  If salting is enabled without a salt function then IndexSalt32 is used.
+func WithSaltFunc(saltFunc SaltFunc) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.saltFunc = saltFunc
+	})
+}
+
+// WithSalts sets per-value salts for the merkle tree, for selective disclosure of values.
+// There must be one 32-byte salt for each piece of data, and each salt should be random and secret.
+func WithSalts(salts [][]byte) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.salts = salts
+	})
+}
+
+// WithRandomSalts sets the merkle tree to generate a random salt for each piece of data, for selective disclosure of values.
+func WithRandomSalts(random bool) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.random = random
+	})
+}
+
 // WithSorted sets the sorted for the merkle tree.
+// Sorted trees order their data by leaf hash; the data supplied is copied rather than reordered in place.
 func WithSorted(sorted bool) Parameter {
 	return parameterFunc(func(p *parameters) {
 		p.sorted = sorted
 	})
 }
 
+// WithPadding sets the padding strategy for the merkle tree.
+func WithPadding(padding Padding) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.padding = padding
+	})
+}
+
+// WithCountMixIn sets the merkle tree to mix the number of values in to its root.
+func WithCountMixIn(countMixIn bool) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.countMixIn = countMixIn
+	})
+}
+
+// WithCount sets the number of values mixed in to the root for the merkle proof, or 0 if the count is not mixed in.
+func WithCount(count uint64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.count = count
+	})
+}
+
+// WithArity sets the number of children of each branch for the merkle tree or proof.  The default is 2.
+func WithArity(arity int) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.arity = arity
+	})
+}
+
+// WithWidth sets the minimum number of leaves of the merkle tree, which is otherwise the number of values rounded up to a power
+// of the arity.  This allows a tree over a partial slice of data to have the same shape as trees over full slices.
+func WithWidth(width uint64) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.width = width
+	})
+}
+
+// WithSpillDir sets the directory in which a builder writes the levels of the tree as they are built, allowing proofs to be
+// generated once the tree is complete.
+func WithSpillDir(dir string) Parameter {
+	return parameterFunc(func(p *parameters) {
+		p.spillDir = dir
+	})
+}
+
 // WithHashType sets the hash type for the merkle tree or proof.
 func WithHashType(hash HashType) Parameter {
 	return parameterFunc(func(p *parameters) {
@@ -89,6 +187,135 @@ func WithHashType(hash HashType) Parameter {
 	})
 }
 
+// resolveSalt ensures that the salt flag and salt function are consistent.
+func (p *parameters) resolveSalt() {
+	if p.saltFunc != nil {
+		p.salt = true
+	} else if p.salt {
+		p.saltFunc = IndexSalt32
+	}
+}
+
+// checkLeafHashes checks the leaf hashes.
+func (p *parameters) checkLeafHashes() error {
+	if len(p.leafHashes) == 0 {
+		return errors.New("tree must have at least 1 leaf hash")
+	}
+	if len(p.data) != 0 || p.dataFunc != nil {
+		return errors.New("cannot use both data and leaf hashes")
+	}
+	if p.salt || p.saltFunc != nil || p.salts != nil || p.random {
+		return errors.New("leaf hashes cannot be salted")
+	}
+	for i := range p.leafHashes {
+		if len(p.leafHashes[i]) != p.hash.HashLength() {
+			return fmt.Errorf("leaf hash at index %d has incorrect length", i)
+		}
+		if err := checkData(p.hash, p.leafHashes[i]); err != nil {
+			return fmt.Errorf("invalid leaf hash at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// checkDataFunc checks the data function.
+func (p *parameters) checkDataFunc() error {
+	if len(p.data) != 0 {
+		return errors.New("cannot use both data and a data function")
+	}
+	if p.dataCount == 0 {
+		return errors.New("tree must have at least 1 piece of data")
+	}
+
+	return nil
+}
+
+// valueCount returns the number of values supplied, either as data or through the data function.
+func (p *parameters) valueCount() uint64 {
+	if p.dataFunc != nil {
+		return p.dataCount
+	}
+
+	return uint64(len(p.data))
+}
+
+// checkSalts checks the per-value salts, generating them if random salts are requested.
+func (p *parameters) checkSalts() error {
+	if p.random {
+		if p.salts != nil {
+			return errors.New("cannot use both random and supplied salts")
+		}
+		p.salts = make([][]byte, p.valueCount())
+		for i := range p.salts {
+			salt, err := randomSalt(p.hash)
+			if err != nil {
+				return err
+			}
+			p.salts[i] = salt
+		}
+	}
+	if p.salts == nil {
+		return nil
+	}
+
+	if p.salt || p.saltFunc != nil {
+		return errors.New("cannot use both per-value salts and index salts")
+	}
+	if uint64(len(p.salts)) != p.valueCount() {
+		return errors.New("salts must be the same length as data")
+	}
+	for i := range p.salts {
+		if len(p.salts[i]) == 0 {
+			return fmt.Errorf("missing salt at index %d", i)
+		}
+		if len(p.salts[i]) != _randomSaltLength {
+			return fmt.Errorf("salt at index %d must be %d bytes", i, _randomSaltLength)
+		}
+	}
+
+	return nil
+}
+
+// randomSalt generates a random salt that can be hashed by the hash type.  Hash types that operate on field elements do not
+// accept all values, so salts are generated until one is accepted.
+func randomSalt(hashType HashType) ([]byte, error) {
+	salt := make([]byte, _randomSaltLength)
+	for i := 0; i < _randomSaltAttempts; i++ {
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		if checkData(hashType, salt) == nil {
+			return salt, nil
+		}
+	}
+
+	return nil, errors.New("failed to generate salt accepted by hash type")
+}
+
+// checkData checks that the data, along with its salts, can be hashed by the hash type.
+func (p *parameters) checkData() error {
+	if _, isChecked := p.hash.(CheckedHashType); !isChecked {
+		return nil
+	}
+	for i := range p.data {
+		if err := checkLeaf(p.hash, p.data[i], p.saltAt(uint64(i))); err != nil {
+			return fmt.Errorf("invalid data at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// saltAt returns the salt for the value at the given index, or nil if values are not salted.
+func (p *parameters) saltAt(index uint64) []byte {
+	if p.salts != nil {
+		return p.salts[index]
+	}
+
+	return saltFor(p.saltFunc, index)
+}
+
 // parseAndCheckTreeParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
 func parseAndCheckTreeParameters(params ...Parameter) (*parameters, error) {
 	parameters := parameters{
@@ -103,9 +330,34 @@ func parseAndCheckTreeParameters(params ...Parameter) (*parameters, error) {
 	if parameters.hash == nil {
 		return nil, errors.New("no hash type specified")
 	}
-	if len(parameters.data) == 0 {
+	if parameters.leafHashes != nil {
+		if err := parameters.checkLeafHashes(); err != nil {
+			return nil, err
+		}
+	} else if parameters.dataFunc != nil {
+		if err := parameters.checkDataFunc(); err != nil {
+			return nil, err
+		}
+	} else if len(parameters.data) == 0 {
 		return nil, errors.New("tree must have at least 1 piece of data")
 	}
+	if err := parameters.checkSalts(); err != nil {
+		return nil, err
+	}
+	parameters.resolveSalt()
+	if err := parameters.checkData(); err != nil {
+		return nil, err
+	}
+
+	if _, exists := paddingNames[parameters.padding]; !exists {
+		return nil, errors.New("unknown padding")
+	}
+	if err := checkArity(parameters.arity); err != nil {
+		return nil, err
+	}
+	if arityOf(parameters.arity) != 2 && parameters.padding == PaddingNone {
+		return nil, errors.New("unbalanced trees must have an arity of 2")
+	}
 
 	if parameters.values != 0 {
 		return nil, errors.New("merkle tree does not use the values parameter")
@@ -116,6 +368,58 @@ func parseAndCheckTreeParameters(params ...Parameter) (*parameters, error) {
 	if len(parameters.indices) != 0 {
 		return nil, errors.New("merkle tree does not use the indices parameter")
 	}
+	if parameters.count != 0 {
+		return nil, errors.New("merkle tree does not use the count parameter")
+	}
+	if parameters.spillDir != "" {
+		return nil, errors.New("merkle tree does not use the spill directory parameter")
+	}
+
+	return &parameters, nil
+}
+
+// parseAndCheckBuilderParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckBuilderParameters(params ...Parameter) (*parameters, error) {
+	return parseAndCheckAppendParameters("builder", params...)
+}
+
+// parseAndCheckAppendParameters parses and checks parameters for trees to which values are appended, where kind describes the
+// tree in errors.
+func parseAndCheckAppendParameters(kind string, params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		hash: blake2b.New(),
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.hash == nil {
+		return nil, errors.New("no hash type specified")
+	}
+	parameters.resolveSalt()
+	if len(parameters.data) != 0 || parameters.dataFunc != nil || parameters.leafHashes != nil {
+		return nil, fmt.Errorf("%s does not use the data parameter", kind)
+	}
+	if parameters.salts != nil || parameters.random {
+		return nil, fmt.Errorf("%s does not use per-value salts", kind)
+	}
+	if parameters.sorted {
+		return nil, fmt.Errorf("%s cannot create sorted trees", kind)
+	}
+	if _, exists := paddingNames[parameters.padding]; !exists {
+		return nil, errors.New("unknown padding")
+	}
+	if err := checkArity(parameters.arity); err != nil {
+		return nil, err
+	}
+	if arityOf(parameters.arity) != 2 && parameters.padding == PaddingNone {
+		return nil, errors.New("unbalanced trees must have an arity of 2")
+	}
+	if parameters.values != 0 || len(parameters.hashes) != 0 || len(parameters.indices) != 0 || parameters.count != 0 {
+		return nil, fmt.Errorf("%s does not use proof parameters", kind)
+	}
 
 	return &parameters, nil
 }
@@ -134,6 +438,7 @@ func parseAndCheckMultiProofParameters(params ...Parameter) (*parameters, error)
 	if parameters.hash == nil {
 		return nil, errors.New("no hash type specified")
 	}
+	parameters.resolveSalt()
 	if parameters.values == 0 {
 		return nil, errors.New("no values specified")
 	}
@@ -142,9 +447,84 @@ func parseAndCheckMultiProofParameters(params ...Parameter) (*parameters, error)
 		return nil, errors.New("no indices specified")
 	}
 
-	if len(parameters.data) != 0 {
+	if len(parameters.data) != 0 || parameters.dataFunc != nil {
 		return nil, errors.New("proof does not use the data parameter")
 	}
+	if parameters.leafHashes != nil {
+		return nil, errors.New("proof does not use the leaf hashes parameter")
+	}
+	if err := checkArity(parameters.arity); err != nil {
+		return nil, err
+	}
+	if parameters.count > leafWidth(parameters.values, arityOf(parameters.arity)) {
+		return nil, errors.New("count cannot be greater than the number of leaves")
+	}
+	if parameters.salts != nil || parameters.random {
+		return nil, errors.New("proof does not use per-value salts")
+	}
 
 	return &parameters, nil
 }
+
+// parseAndCheckUpdateParameters parses and checks parameters for computing updated roots from proofs.  These are the parameters
+// of the tree, which must be able to have its root updated from a proof alone.
+func parseAndCheckUpdateParameters(params ...Parameter) (*parameters, error) {
+	parameters := parameters{
+		hash: blake2b.New(),
+	}
+	for _, p := range params {
+		if params != nil {
+			p.apply(&parameters)
+		}
+	}
+
+	if parameters.hash == nil {
+		return nil, errors.New("no hash type specified")
+	}
+	parameters.resolveSalt()
+	if len(parameters.data) != 0 || parameters.dataFunc != nil || parameters.leafHashes != nil {
+		return nil, errors.New("update does not use the data parameter")
+	}
+	if parameters.values != 0 || len(parameters.hashes) != 0 || len(parameters.indices) != 0 || parameters.count != 0 {
+		return nil, errors.New("update does not use proof parameters")
+	}
+	if parameters.salts != nil || parameters.random {
+		return nil, errors.New("update does not use per-value salts")
+	}
+	if parameters.sorted {
+		return nil, errors.New("roots of sorted trees cannot be updated")
+	}
+	if _, exists := paddingNames[parameters.padding]; !exists {
+		return nil, errors.New("unknown padding")
+	}
+	if parameters.padding == PaddingDuplicateLast {
+		return nil, errors.New("roots of trees with duplicate-last padding cannot be updated")
+	}
+	if err := checkArity(parameters.arity); err != nil {
+		return nil, err
+	}
+
+	return &parameters, nil
+}
+
+// parseAndCheckIncrementalParameters parses and checks parameters to ensure that mandatory parameters are present and correct.
+func parseAndCheckIncrementalParameters(params ...Parameter) (*parameters, error) {
+	parameters, err := parseAndCheckAppendParameters("incremental tree", params...)
+	if err != nil {
+		return nil, err
+	}
+	if parameters.padding != PaddingZeroHash && parameters.padding != PaddingEmptyHash {
+		return nil, errors.New("incremental trees must use zero-hash or empty-hash padding")
+	}
+	if arityOf(parameters.arity) != 2 {
+		return nil, errors.New("incremental trees must have an arity of 2")
+	}
+	if parameters.width != 0 {
+		return nil, errors.New("incremental trees do not use the width parameter")
+	}
+	if parameters.spillDir != "" {
+		return nil, errors.New("incremental trees do not use the spill directory parameter")
+	}
+
+	return parameters, nil
+}
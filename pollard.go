@@ -30,8 +30,55 @@ func VerifyPollardUsing(pollard [][]byte, hashType HashType) bool {
 		// If there is only a single hash it is automatically correct
 		return true
 	}
+	hasher := newHasher(hashType)
+	var hash []byte
 	for i := len(pollard)/2 - 1; i >= 0; i-- {
-		if !bytes.Equal(pollard[i], hashType.Hash(pollard[i*2+1], pollard[i*2+2])) {
+		if pollard[i*2+2] == nil {
+			// The right subtree is empty in an unbalanced tree, so the branch has the same value as its left child.
+			if !bytes.Equal(pollard[i], pollard[i*2+1]) {
+				return false
+			}
+
+			continue
+		}
+		hash = hasher.hashBranch(hash, pollard[i*2+1], pollard[i*2+2])
+		if !bytes.Equal(pollard[i], hash) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// VerifyPollardWithArity ensures that the branches in the pollard of a tree with the given arity match up with the root using the
+// supplied hash type.
+func VerifyPollardWithArity(pollard [][]byte, arity int, hashType HashType) bool {
+	if checkArity(arity) != nil {
+		return false
+	}
+	k := arityOf(arity)
+	if k == 2 {
+		return VerifyPollardUsing(pollard, hashType)
+	}
+	// The pollard must contain complete levels.
+	length := 0
+	for width := 1; length < len(pollard); width *= int(k) {
+		length += width
+	}
+	if length != len(pollard) {
+		return false
+	}
+
+	hasher := newHasher(hashType)
+	var hash []byte
+	// Pollard entries are offset by one from the indices of their nodes.
+	for i := uint64(len(pollard)); i > 0; i-- {
+		first := firstChild(i, k) - 1
+		if first+k > uint64(len(pollard)) {
+			continue
+		}
+		hash = hasher.hashChildren(hash, pollard[first:first+k])
+		if !bytes.Equal(pollard[i-1], hash) {
 			return false
 		}
 	}
@@ -12,27 +12,56 @@
 // limitations under the License.
 
 // Package poseidon provides hashing using the Poseidon system.
+//
+// Two modes are available.  The default mode hashes the concatenation of its inputs as a byte stream.  The field element mode,
+// created with NewFieldElements(), treats each input as a single field element and hashes them together, which matches the way
+// that circom and iden3 circuits hash leaves and branches.
 package poseidon
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"os"
 
 	"github.com/iden3/go-iden3-crypto/poseidon"
+	"github.com/iden3/go-iden3-crypto/utils"
 )
 
 const _hashlength = 32
 
 // Poseidon is the Poseidon hashing method.
-type Poseidon struct{}
+type Poseidon struct {
+	// fieldElements is true if inputs are treated as field elements.
+	fieldElements bool
+}
 
 // New creates a new Poseidon hashing method with `frameSize` set to 16.
 func New() *Poseidon {
 	return &Poseidon{}
 }
 
+// NewFieldElements creates a new Poseidon hashing method that treats each input as a field element.
+// Each input must be the big-endian representation of a value in the BN254 scalar field, and be no more than 32 bytes long.
+// Leaves are hashed as Poseidon([value]), or Poseidon([value, salt]) if salted, and branches as Poseidon([left, right]).
+func NewFieldElements() *Poseidon {
+	return &Poseidon{
+		fieldElements: true,
+	}
+}
+
 // Hash generates a Poseidon hash from a byte array.
-func (*Poseidon) Hash(data ...[]byte) []byte {
+// In field element mode this returns nil if any input is not a valid field element; use HashFieldElements() to obtain the error.
+func (h *Poseidon) Hash(data ...[]byte) []byte {
+	if h.fieldElements {
+		hash, err := h.HashFieldElements(data...)
+		if err != nil {
+			return nil
+		}
+
+		return hash
+	}
+
 	for i := range data {
 		if len(data[i])%32 != 0 {
 			_, _ = fmt.Fprintln(os.Stderr, "Poseidon hash implementation is not secure with arbitrary length byte arrays; please provide details of your usage at https://github.com/wealdtech/go-merkletree/issues/19")
@@ -59,12 +88,75 @@ func (*Poseidon) Hash(data ...[]byte) []byte {
 	return hash
 }
 
+// HashFieldElements generates a Poseidon hash of the inputs, each of which is treated as a single field element.
+// The result is a 32-byte big-endian field element.
+func (*Poseidon) HashFieldElements(data ...[]byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no data supplied")
+	}
+
+	elements := make([]*big.Int, len(data))
+	for i := range data {
+		element, err := FieldElement(data[i])
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element
+	}
+
+	hash, err := poseidon.Hash(elements)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.FillBytes(make([]byte, _hashlength)), nil
+}
+
+// CheckData returns an error if the data cannot be hashed.
+// In the default mode all data can be hashed; in field element mode the data must be a valid field element.
+func (h *Poseidon) CheckData(data []byte) error {
+	if !h.fieldElements {
+		return nil
+	}
+	_, err := FieldElement(data)
+
+	return err
+}
+
+// FieldElement decodes big-endian data as a field element.
+// This is only available in field element mode.
+func (h *Poseidon) FieldElement(data []byte) (*big.Int, error) {
+	if !h.fieldElements {
+		return nil, errors.New("not in field element mode")
+	}
+
+	return FieldElement(data)
+}
+
+// FieldElement decodes big-endian data as a field element.
+// An error is returned if the data is longer than 32 bytes or its value is not inside the field.
+func FieldElement(data []byte) (*big.Int, error) {
+	if len(data) > _hashlength {
+		return nil, fmt.Errorf("field element must be at most %d bytes", _hashlength)
+	}
+	element := new(big.Int).SetBytes(data)
+	if !utils.CheckBigIntInField(element) {
+		return nil, errors.New("value not inside field")
+	}
+
+	return element, nil
+}
+
 // HashLength returns the length of hashes generated by Hash() in bytes.
 func (*Poseidon) HashLength() int {
 	return _hashlength
 }
 
 // HashName returns the name of this hash.
-func (*Poseidon) HashName() string {
+func (h *Poseidon) HashName() string {
+	if h.fieldElements {
+		return "poseidon-field"
+	}
+
 	return "poseidon"
 }
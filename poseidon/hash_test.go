@@ -97,3 +97,52 @@ func TestPadding(t *testing.T) {
 		assert.NotEqual(t, test.output, paddedOutput, fmt.Sprintf("hash of %x same as hash of %x", test.data, paddedData))
 	}
 }
+
+func TestFieldElements(t *testing.T) {
+	tests := []struct {
+		data   [][]byte
+		output []byte
+		err    string
+	}{
+		{ // 0
+			data:   [][]byte{{0x01}},
+			output: _byteArray("29176100eaa962bdc1fe6c654d6a3c130e96a4d1168b33848b897dc502820133"),
+		},
+		{ // 1
+			data:   [][]byte{{0x01}, {0x02}},
+			output: _byteArray("115cc0f5e7d690413df64c6b9662e9cf2a3617f2743245519e19607a4417189a"),
+		},
+		{ // 2
+			data: [][]byte{
+				_byteArray("0000000000000000000000000000000000000000000000000000000000000001"),
+				_byteArray("0000000000000000000000000000000000000000000000000000000000000002"),
+			},
+			output: _byteArray("115cc0f5e7d690413df64c6b9662e9cf2a3617f2743245519e19607a4417189a"),
+		},
+		{ // 3
+			data: [][]byte{_byteArray("30644e72e131a029b85045b68181585d2833e84879b9709143e1f593f0000001")},
+			err:  "value not inside field",
+		},
+		{ // 4
+			data: [][]byte{make([]byte, 33)},
+			err:  "field element must be at most 32 bytes",
+		},
+		{ // 5
+			err: "no data supplied",
+		},
+	}
+
+	hash := poseidon.NewFieldElements()
+	assert.Equal(t, "poseidon-field", hash.HashName())
+	for i, test := range tests {
+		output, err := hash.HashFieldElements(test.data...)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, fmt.Sprintf("incorrect error at test %d", i))
+			assert.Nil(t, hash.Hash(test.data...), fmt.Sprintf("unexpected output at test %d", i))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error at test %d", i))
+			assert.Equal(t, test.output, output, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, test.output, hash.Hash(test.data...), fmt.Sprintf("failed at test %d", i))
+		}
+	}
+}
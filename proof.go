@@ -15,7 +15,8 @@ package merkletree
 
 import (
 	"bytes"
-	"encoding/binary"
+	"errors"
+	"fmt"
 
 	"github.com/wealdtech/go-merkletree/v2/blake2b"
 )
@@ -24,6 +25,11 @@ import (
 type Proof struct {
 	Hashes [][]byte
 	Index  uint64
+	// Count is the number of values in the tree if it is mixed in to the root, otherwise 0.
+	Count uint64 `json:",omitempty"`
+	// Arity is the number of children of each branch in the tree, or 0 if the tree is binary.
+	// Each level of the proof contains the arity-1 siblings of the node at that level, in order.
+	Arity int `json:",omitempty"`
 }
 
 // newProof generates a Merkle proof.
@@ -51,35 +57,188 @@ func VerifyProof(data []byte, salt bool, proof *Proof, pollard [][]byte) (bool,
 //
 // This returns true if the proof is verified, otherwise false.
 func VerifyProofUsing(data []byte, salt bool, proof *Proof, pollard [][]byte, hashType HashType) (bool, error) {
-	proofHash := generateProofHash(data, salt, proof, hashType)
-	for i := 0; i < len(pollard)/2+1; i++ {
-		if bytes.Equal(pollard[len(pollard)-1-i], proofHash) {
-			return true, nil
+	if err := checkData(hashType, data); err != nil {
+		return false, err
+	}
+
+	return VerifyProofUsingSaltFunc(data, saltFuncFor(salt), proof, pollard, hashType)
+}
+
+// VerifyProofUsingSaltFunc verifies a Merkle tree proof for a piece of data using the provided salt function and hash type.
+// The salt function must be the same as that used to create the tree, or nil if the tree is not salted.
+//
+// This returns true if the proof is verified, otherwise false.
+func VerifyProofUsingSaltFunc(data []byte, saltFunc SaltFunc, proof *Proof, pollard [][]byte, hashType HashType) (bool, error) {
+	salt := saltFor(saltFunc, proof.Index)
+	if err := checkLeaf(hashType, data, salt); err != nil {
+		return false, err
+	}
+	if err := checkHashes(hashType, proof.Hashes); err != nil {
+		return false, err
+	}
+
+	if !proof.valid() {
+		return false, nil
+	}
+
+	proofHash := generateProofHash(data, salt, proof, false, hashType)
+	return inPollard(pollard, proofHash, proof.arity()), nil
+}
+
+// VerifyProofUsingLeafHash verifies a Merkle tree proof for a leaf hash using the provided hash type.
+// This allows verification of proofs without access to the data, for example with trees created using WithLeafHashes().
+//
+// This returns true if the proof is verified, otherwise false.
+func VerifyProofUsingLeafHash(leafHash []byte, proof *Proof, pollard [][]byte, hashType HashType) (bool, error) {
+	if len(leafHash) != hashType.HashLength() {
+		return false, errors.New("leaf hash has incorrect length")
+	}
+	if err := checkData(hashType, leafHash); err != nil {
+		return false, fmt.Errorf("invalid leaf hash: %w", err)
+	}
+	if err := checkHashes(hashType, proof.Hashes); err != nil {
+		return false, err
+	}
+
+	if !proof.valid() {
+		return false, nil
+	}
+
+	proofHash := generateProofHashFromLeaf(newHasher(hashType), leafHash, proof, false)
+
+	return inPollard(pollard, proofHash, proof.arity()), nil
+}
+
+// ComputeUpdatedRoot computes the root of the tree after the value at the index of the proof is replaced with the new data.
+// This allows a verifier holding only a root and a proof to follow changes to the tree; the existing data should first be
+// verified against the existing root.  If the proof is to a pollard the result is the updated node in the pollard.
+//
+// The parameters of the tree other than its data are supplied as parameters; by default the hash type is BLAKE2b and values are
+// not salted.  If an arity is supplied it must match that of the proof.  Roots of sorted trees cannot be updated, as changing a
+// value can change the order of the leaves, nor can those of trees with per-value salts, as the salt of the new value is not
+// known.  Roots of trees padded with PaddingDuplicateLast cannot be updated, as changing the last value would also change the
+// padding leaves.
+func (p *Proof) ComputeUpdatedRoot(newData []byte, params ...Parameter) ([]byte, error) {
+	parameters, err := parseAndCheckUpdateParameters(params...)
+	if err != nil {
+		return nil, err
+	}
+	if parameters.arity != 0 && arityOf(parameters.arity) != p.arity() {
+		return nil, errors.New("arity does not match proof")
+	}
+
+	salt := saltFor(parameters.saltFunc, p.Index)
+	if err := checkLeaf(parameters.hash, newData, salt); err != nil {
+		return nil, err
+	}
+	if err := checkHashes(parameters.hash, p.Hashes); err != nil {
+		return nil, err
+	}
+	if !p.valid() {
+		return nil, errors.New("invalid proof")
+	}
+
+	return generateProofHash(newData, salt, p, false, parameters.hash), nil
+}
+
+// inPollard returns true if the hash is present in the highest level of the pollard.
+func inPollard(pollard [][]byte, hash []byte, arity uint64) bool {
+	width := pollardWidth(len(pollard), arity)
+	for i := 0; i < width; i++ {
+		if bytes.Equal(pollard[len(pollard)-1-i], hash) {
+			return true
 		}
 	}
 
-	return false, nil
+	return false
+}
+
+// generateProofHash generates the root of the proof for the data with the given salt, which can be nil if unsalted.
+// If sorted is true the branches are ordered by value rather than by position.
+func generateProofHash(data []byte, salt []byte, proof *Proof, sorted bool, hashType HashType) []byte {
+	hasher := newHasher(hashType)
+
+	return generateProofHashFromLeaf(hasher, hasher.hashLeaf(nil, data, salt), proof, sorted)
 }
 
-func generateProofHash(data []byte, salt bool, proof *Proof, hashType HashType) []byte {
-	var proofHash []byte
-	if salt {
-		indexSalt := make([]byte, 4)
-		binary.BigEndian.PutUint32(indexSalt, uint32(proof.Index))
-		proofHash = hashType.Hash(data, indexSalt)
+// generateProofHashFromLeaf generates the root of the proof for the leaf hash.
+// If the proof has a count it is mixed in to the result.
+// If sorted is true the branches are ordered by value rather than by position.
+func generateProofHashFromLeaf(hasher *hasher, leafHash []byte, proof *Proof, sorted bool) []byte {
+	// Take a copy of the leaf hash as the streaming hasher writes to it.
+	proofHash := append(make([]byte, 0, len(leafHash)), leafHash...)
+	if arity := proof.arity(); arity != 2 {
+		proofHash = generateKaryProofHash(hasher, proofHash, proof, arity, sorted)
 	} else {
-		proofHash = hashType.Hash(data)
+		proofHash = generateBinaryProofHash(hasher, proofHash, proof, sorted)
+	}
+
+	if proof.Count != 0 {
+		proofHash = mixInCount(hasher, proofHash, proof.Count)
 	}
+
+	return proofHash
+}
+
+// generateBinaryProofHash generates the root of the proof for a binary tree, writing to proofHash.
+func generateBinaryProofHash(hasher *hasher, proofHash []byte, proof *Proof, sorted bool) []byte {
 	index := proof.Index + (1 << uint(len(proof.Hashes)))
 
 	for _, hash := range proof.Hashes {
-		if index%2 == 0 {
-			proofHash = hashType.Hash(proofHash, hash)
+		if hash == nil {
+			// The sibling subtree is empty in an unbalanced tree, so the branch has the same value as this node.
+			index >>= 1
+
+			continue
+		}
+		if sorted {
+			if bytes.Compare(proofHash, hash) == 1 {
+				proofHash = hasher.hashBranch(proofHash, hash, proofHash)
+			} else {
+				proofHash = hasher.hashBranch(proofHash, proofHash, hash)
+			}
+		} else if index%2 == 0 {
+			proofHash = hasher.hashBranch(proofHash, proofHash, hash)
 		} else {
-			proofHash = hashType.Hash(hash, proofHash)
+			proofHash = hasher.hashBranch(proofHash, hash, proofHash)
 		}
 		index >>= 1
 	}
 
 	return proofHash
 }
+
+// generateKaryProofHash generates the root of the proof for a tree with an arity greater than 2, writing to proofHash.
+func generateKaryProofHash(hasher *hasher, proofHash []byte, proof *Proof, arity uint64, sorted bool) []byte {
+	siblings := int(arity - 1)
+	children := make([][]byte, arity)
+	index := proof.Index
+	for level := 0; level < len(proof.Hashes); level += siblings {
+		// Place the node amongst its siblings.
+		position := index % arity
+		copy(children, proof.Hashes[level:level+int(position)])
+		children[position] = proofHash
+		copy(children[position+1:], proof.Hashes[level+int(position):level+siblings])
+		if sorted {
+			sortHashes(children)
+		}
+		proofHash = hasher.hashChildren(proofHash, children)
+		index /= arity
+	}
+
+	return proofHash
+}
+
+// arity returns the arity of the proof.
+func (p *Proof) arity() uint64 {
+	return arityOf(p.Arity)
+}
+
+// valid returns false if the proof is malformed, or if it has a count and its index is outside of it.
+func (p *Proof) valid() bool {
+	if checkArity(p.Arity) != nil || len(p.Hashes)%int(p.arity()-1) != 0 {
+		return false
+	}
+
+	return p.Count == 0 || p.Index < p.Count
+}
@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
 )
 
 func TestProofWithIndex(t *testing.T) {
@@ -181,3 +183,86 @@ func TestProofRandom(t *testing.T) {
 		assert.True(t, proven, fmt.Sprintf("failed to verify proof at test %d", i))
 	}
 }
+
+func TestProofComputeUpdatedRoot(t *testing.T) {
+	tests := []struct {
+		values int
+		params []Parameter
+	}{
+		{
+			values: 1,
+		},
+		{
+			values: 7,
+			params: []Parameter{WithHashType(keccak256.New()), WithSalt(true)},
+		},
+		{
+			values: 10,
+			params: []Parameter{WithPadding(PaddingEmptyHash), WithCountMixIn(true)},
+		},
+		{
+			values: 11,
+			params: []Parameter{WithPadding(PaddingNone)},
+		},
+		{
+			values: 20,
+			params: []Parameter{WithArity(4), WithSaltFunc(IndexSalt64)},
+		},
+	}
+
+	for i, test := range tests {
+		data := diffData(test.values)
+		tree, err := NewTree(append([]Parameter{WithData(data)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		for index := range data {
+			proof, err := tree.GenerateProofWithIndex(uint64(index), 0)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+			updatedData := diffData(test.values, index)
+			updated, err := NewTree(append([]Parameter{WithData(updatedData)}, test.params...)...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+			root, err := proof.ComputeUpdatedRoot(updatedData[index], test.params...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, updated.Root(), root, fmt.Sprintf("failed at test %d index %d", i, index))
+
+			// The proof is unchanged by the update, so remains valid for the new data against the new root.
+			verified, err := VerifyProofUsingSaltFunc(updatedData[index], updated.saltFunc(), proof, [][]byte{root}, updated.Hash)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.True(t, verified, fmt.Sprintf("failed at test %d index %d", i, index))
+		}
+	}
+}
+
+func TestProofComputeUpdatedRootErrors(t *testing.T) {
+	tree, err := NewTree(WithData(diffData(4)))
+	require.NoError(t, err)
+	proof, err := tree.GenerateProofWithIndex(1, 0)
+	require.NoError(t, err)
+
+	root, err := proof.ComputeUpdatedRoot([]byte("value 1"))
+	require.NoError(t, err)
+	assert.Equal(t, tree.Root(), root)
+
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithHashType(nil))
+	assert.EqualError(t, err, "no hash type specified")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithSorted(true))
+	assert.EqualError(t, err, "roots of sorted trees cannot be updated")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithData(diffData(4)))
+	assert.EqualError(t, err, "update does not use the data parameter")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithIndices([]uint64{1}))
+	assert.EqualError(t, err, "update does not use proof parameters")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithSalts(diffData(4)))
+	assert.EqualError(t, err, "update does not use per-value salts")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithRandomSalts(true))
+	assert.EqualError(t, err, "update does not use per-value salts")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithPadding(PaddingDuplicateLast))
+	assert.EqualError(t, err, "roots of trees with duplicate-last padding cannot be updated")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithArity(3))
+	assert.EqualError(t, err, "arity must be 2, 4, 8 or 16")
+	_, err = proof.ComputeUpdatedRoot([]byte("new"), WithArity(4))
+	assert.EqualError(t, err, "arity does not match proof")
+	_, err = (&Proof{Hashes: proof.Hashes, Index: 4, Count: 4}).ComputeUpdatedRoot([]byte("new"))
+	assert.EqualError(t, err, "invalid proof")
+}
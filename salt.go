@@ -0,0 +1,91 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/binary"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// SaltFunc generates the salt for the value at the given index of a tree.
+type SaltFunc func(index uint64) []byte
+
+// IndexSalt32 salts values with the 4-byte big-endian representation of their index.
+// This is the salt used by WithSalt(), and wraps if there are more than 2^32 values in the tree.
+func IndexSalt32(index uint64) []byte {
+	salt := make([]byte, 4)
+	binary.BigEndian.PutUint32(salt, uint32(index))
+
+	return salt
+}
+
+// IndexSalt64 salts values with the 8-byte big-endian representation of their index.
+func IndexSalt64(index uint64) []byte {
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, index)
+
+	return salt
+}
+
+// saltFuncs are the salt functions that can be identified by name.
+var saltFuncs = map[string]SaltFunc{
+	"index32": IndexSalt32,
+	"index64": IndexSalt64,
+}
+
+// saltFuncName returns the name of the salt function, or "custom" if it is not a built-in salt function.
+func saltFuncName(saltFunc SaltFunc) string {
+	if saltFunc == nil {
+		return ""
+	}
+	pointer := reflect.ValueOf(saltFunc).Pointer()
+	for name, f := range saltFuncs {
+		if reflect.ValueOf(f).Pointer() == pointer {
+			return name
+		}
+	}
+
+	return "custom"
+}
+
+// saltFuncFromName returns the salt function for the given name.
+// Custom salt functions cannot be obtained from their name.
+func saltFuncFromName(name string) (SaltFunc, error) {
+	saltFunc, exists := saltFuncs[name]
+	if !exists {
+		return nil, errors.New("cannot parse salt function")
+	}
+
+	return saltFunc, nil
+}
+
+// saltFuncFor returns the salt function for the legacy salt flag.
+func saltFuncFor(salt bool) SaltFunc {
+	if salt {
+		return IndexSalt32
+	}
+
+	return nil
+}
+
+// saltFor returns the salt for the given index, or nil if the salt function is nil.
+func saltFor(saltFunc SaltFunc, index uint64) []byte {
+	if saltFunc == nil {
+		return nil
+	}
+
+	return saltFunc(index)
+}
@@ -0,0 +1,89 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestIndexSalts(t *testing.T) {
+	tests := []struct {
+		index  uint64
+		salt32 []byte
+		salt64 []byte
+	}{
+		{
+			index:  0,
+			salt32: _byteArray("00000000"),
+			salt64: _byteArray("0000000000000000"),
+		},
+		{
+			index:  0x01020304,
+			salt32: _byteArray("01020304"),
+			salt64: _byteArray("0000000001020304"),
+		},
+		{
+			index:  0x0102030405,
+			salt32: _byteArray("02030405"),
+			salt64: _byteArray("0000000102030405"),
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.salt32, IndexSalt32(test.index), fmt.Sprintf("failed at test %d", i))
+		assert.Equal(t, test.salt64, IndexSalt64(test.index), fmt.Sprintf("failed at test %d", i))
+	}
+}
+
+func TestSaltFunc(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+
+	legacyTree, err := NewTree(WithData(data), WithHashType(keccak256.New()), WithSalt(true))
+	require.NoError(t, err)
+	index32Tree, err := NewTree(WithData(data), WithHashType(keccak256.New()), WithSaltFunc(IndexSalt32))
+	require.NoError(t, err)
+	require.Equal(t, legacyTree.Root(), index32Tree.Root())
+
+	tree, err := NewTree(WithData(data), WithHashType(keccak256.New()), WithSaltFunc(IndexSalt64))
+	require.NoError(t, err)
+	require.True(t, tree.GetSalt())
+	require.NotEqual(t, legacyTree.Root(), tree.Root())
+
+	for i := range data {
+		proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		verified, err := VerifyProofUsingSaltFunc(data[i], IndexSalt64, proof, [][]byte{tree.Root()}, keccak256.New())
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.True(t, verified, fmt.Sprintf("failed at index %d", i))
+		verified, err = VerifyProofUsing(data[i], true, proof, [][]byte{tree.Root()}, keccak256.New())
+		require.NoError(t, err, fmt.Sprintf("failed at index %d", i))
+		assert.False(t, verified, fmt.Sprintf("failed at index %d", i))
+	}
+
+	multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{0, 2})
+	require.NoError(t, err)
+	verified, err := multiProof.Verify([][]byte{data[0], data[2]}, tree.Root())
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// The DOT representation labels leaves with the full salt.
+	dot := tree.DOT(new(StringFormatter), nil)
+	assert.True(t, strings.Contains(dot, `"Baz"->6 [label="+0000000000000002"];`))
+}
@@ -15,6 +15,8 @@
 package sha3
 
 import (
+	"hash"
+
 	"golang.org/x/crypto/sha3"
 )
 
@@ -59,3 +61,8 @@ func (*SHA256) Hash(data ...[]byte) []byte {
 
 	return hash[:]
 }
+
+// NewHasher returns a new streaming SHA3 hasher.
+func (*SHA256) NewHasher() hash.Hash {
+	return sha3.New256()
+}
@@ -14,6 +14,8 @@
 package sha3
 
 import (
+	"hash"
+
 	"golang.org/x/crypto/sha3"
 )
 
@@ -58,3 +60,8 @@ func (*SHA512) Hash(data ...[]byte) []byte {
 
 	return hash[:]
 }
+
+// NewHasher returns a new streaming SHA3 hasher.
+func (*SHA512) NewHasher() hash.Hash {
+	return sha3.New512()
+}
@@ -0,0 +1,218 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solidity
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	verifySignature      = "verify(bytes32,bytes,uint256,bytes32[])"
+	verifyMultiSignature = "verifyMulti(bytes32,bytes[],uint256[],uint256,uint256[],bytes32[])"
+)
+
+// ProofCalldata generates the calldata to call the generated library's verify() function for the proof of data against root.
+// Proofs of trees with per-value salts are not supported, as libraries cannot be generated for them, nor are proofs with a
+// mixed-in count or an arity other than 2.
+func ProofCalldata(root []byte, data []byte, proof *merkletree.Proof) ([]byte, error) {
+	if len(root) != 32 {
+		return nil, errors.New("root must be 32 bytes")
+	}
+	if proof == nil {
+		return nil, errors.New("no proof specified")
+	}
+	if err := checkProof(proof.Count, proof.Arity); err != nil {
+		return nil, err
+	}
+	for i := range proof.Hashes {
+		if len(proof.Hashes[i]) != 32 {
+			return nil, errors.New("proof hashes must be 32 bytes")
+		}
+	}
+
+	return encodeCall(verifySignature,
+		staticArgument(root),
+		dynamicArgument(encodeBytes(data)),
+		staticArgument(encodeUint64(proof.Index)),
+		dynamicArgument(encodeBytes32Array(proof.Hashes)),
+	), nil
+}
+
+// MultiProofCalldata generates the calldata to call the generated library's verifyMulti() function for the multiproof of data
+// against root.  Data must be in the same order as the multiproof's indices.  Multiproofs with a mixed-in count or an arity other
+// than 2 are not supported.
+func MultiProofCalldata(root []byte, data [][]byte, proof *merkletree.MultiProof) ([]byte, error) {
+	if len(root) != 32 {
+		return nil, errors.New("root must be 32 bytes")
+	}
+	if proof == nil {
+		return nil, errors.New("no proof specified")
+	}
+	if err := checkProof(proof.Count, proof.Arity); err != nil {
+		return nil, err
+	}
+	if len(data) != len(proof.Indices) {
+		return nil, errors.New("data and indices must be the same length")
+	}
+
+	// The library requires leaves and hashes in descending order of their generalized indices.
+	leafOrder := make([]int, len(proof.Indices))
+	for i := range leafOrder {
+		leafOrder[i] = i
+	}
+	sort.Slice(leafOrder, func(i, j int) bool {
+		return proof.Indices[leafOrder[i]] > proof.Indices[leafOrder[j]]
+	})
+	sortedData := make([][]byte, len(data))
+	sortedIndices := make([]uint64, len(proof.Indices))
+	for i, j := range leafOrder {
+		if i > 0 && proof.Indices[j] == sortedIndices[i-1] {
+			return nil, errors.New("duplicate index")
+		}
+		sortedData[i] = data[j]
+		sortedIndices[i] = proof.Indices[j]
+	}
+
+	// Hashes that can be calculated from the data, which are present if the multiproof has been verified, are not required.
+	calculated := make(map[uint64]bool)
+	for _, index := range proof.Indices {
+		for i := index + proof.Values; i > 0; i /= 2 {
+			calculated[i] = true
+		}
+	}
+	hashIndices := make([]uint64, 0, len(proof.Hashes))
+	for index := range proof.Hashes {
+		if !calculated[index] {
+			hashIndices = append(hashIndices, index)
+		}
+	}
+	sort.Slice(hashIndices, func(i, j int) bool {
+		return hashIndices[i] > hashIndices[j]
+	})
+	hashes := make([][]byte, len(hashIndices))
+	for i, index := range hashIndices {
+		hashes[i] = proof.Hashes[index]
+		if len(hashes[i]) != 32 {
+			return nil, errors.New("proof hashes must be 32 bytes")
+		}
+	}
+
+	return encodeCall(verifyMultiSignature,
+		staticArgument(root),
+		dynamicArgument(encodeBytesArray(sortedData)),
+		dynamicArgument(encodeUint64Array(sortedIndices)),
+		staticArgument(encodeUint64(proof.Values)),
+		dynamicArgument(encodeUint64Array(hashIndices)),
+		dynamicArgument(encodeBytes32Array(hashes)),
+	), nil
+}
+
+// checkProof checks that a proof with the given count and arity can be verified by a generated library.
+func checkProof(count uint64, arity int) error {
+	if count != 0 {
+		return errors.New("proofs with a mixed-in count are not supported")
+	}
+	if arity != 0 && arity != 2 {
+		return errors.New("proofs with an arity other than 2 are not supported")
+	}
+
+	return nil
+}
+
+// argument is an ABI-encoded argument.
+type argument struct {
+	encoded []byte
+	dynamic bool
+}
+
+func staticArgument(encoded []byte) argument {
+	return argument{encoded: encoded}
+}
+
+func dynamicArgument(encoded []byte) argument {
+	return argument{encoded: encoded, dynamic: true}
+}
+
+// encodeCall encodes a function call as per the Solidity ABI.
+func encodeCall(signature string, args ...argument) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(signature))
+
+	return append(hash.Sum(nil)[:4], encodeArguments(args...)...)
+}
+
+// encodeArguments encodes a tuple of arguments, with static arguments in the head and dynamic arguments in the tail.
+func encodeArguments(args ...argument) []byte {
+	head := make([]byte, 0, 32*len(args))
+	tail := make([]byte, 0)
+	for _, arg := range args {
+		if arg.dynamic {
+			head = append(head, encodeUint64(uint64(32*len(args)+len(tail)))...)
+			tail = append(tail, arg.encoded...)
+		} else {
+			head = append(head, arg.encoded...)
+		}
+	}
+
+	return append(head, tail...)
+}
+
+func encodeUint64(value uint64) []byte {
+	res := make([]byte, 32)
+	binary.BigEndian.PutUint64(res[24:], value)
+
+	return res
+}
+
+func encodeBytes(data []byte) []byte {
+	res := encodeUint64(uint64(len(data)))
+	res = append(res, data...)
+	if len(data)%32 != 0 {
+		res = append(res, make([]byte, 32-len(data)%32)...)
+	}
+
+	return res
+}
+
+func encodeBytes32Array(values [][]byte) []byte {
+	res := encodeUint64(uint64(len(values)))
+	for i := range values {
+		res = append(res, values[i]...)
+	}
+
+	return res
+}
+
+func encodeUint64Array(values []uint64) []byte {
+	res := encodeUint64(uint64(len(values)))
+	for i := range values {
+		res = append(res, encodeUint64(values[i])...)
+	}
+
+	return res
+}
+
+func encodeBytesArray(values [][]byte) []byte {
+	args := make([]argument, len(values))
+	for i := range values {
+		args[i] = dynamicArgument(encodeBytes(values[i]))
+	}
+
+	return append(encodeUint64(uint64(len(values))), encodeArguments(args...)...)
+}
@@ -0,0 +1,336 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solidity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"golang.org/x/crypto/sha3"
+)
+
+// _byteArray is a helper to turn a string in to a byte array
+func _byteArray(input string) []byte {
+	x, err := hex.DecodeString(strings.ReplaceAll(input, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func TestEncodeCall(t *testing.T) {
+	// Selector of the ERC-20 transfer function.
+	require.Equal(t, _byteArray("a9059cbb"), encodeCall("transfer(address,uint256)"))
+}
+
+func TestEncodeBytesArray(t *testing.T) {
+	// Encoding of ["one", "two", "three"] from the Solidity ABI specification.
+	expected := _byteArray("0000000000000000000000000000000000000000000000000000000000000003" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"00000000000000000000000000000000000000000000000000000000000000a0" +
+		"00000000000000000000000000000000000000000000000000000000000000e0" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"6f6e650000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000003" +
+		"74776f0000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"7468726565000000000000000000000000000000000000000000000000000000")
+	require.Equal(t, expected, encodeBytesArray([][]byte{[]byte("one"), []byte("two"), []byte("three")}))
+}
+
+// calldataReader reads ABI-encoded arguments.
+type calldataReader struct {
+	data []byte
+}
+
+func (r *calldataReader) word(offset int) []byte {
+	return r.data[offset : offset+32]
+}
+
+func (r *calldataReader) uint(offset int) uint64 {
+	return binary.BigEndian.Uint64(r.data[offset+24 : offset+32])
+}
+
+// tail returns a reader for the dynamic argument whose offset is held at the given position of a tuple starting at base.
+func (r *calldataReader) tail(base int, position int) (int, uint64) {
+	start := base + int(r.uint(base+position))
+	return start + 32, r.uint(start)
+}
+
+func (r *calldataReader) bytes(base int, position int) []byte {
+	start, length := r.tail(base, position)
+	return r.data[start : start+int(length)]
+}
+
+func (r *calldataReader) words(base int, position int) [][]byte {
+	start, length := r.tail(base, position)
+	res := make([][]byte, length)
+	for i := range res {
+		res[i] = r.word(start + 32*i)
+	}
+	return res
+}
+
+func (r *calldataReader) uints(base int, position int) []uint64 {
+	start, length := r.tail(base, position)
+	res := make([]uint64, length)
+	for i := range res {
+		res[i] = r.uint(start + 32*i)
+	}
+	return res
+}
+
+func (r *calldataReader) bytesArray(base int, position int) [][]byte {
+	start, length := r.tail(base, position)
+	res := make([][]byte, length)
+	for i := range res {
+		res[i] = r.bytes(start, 32*i)
+	}
+	return res
+}
+
+// model is a Go model of the verification logic of the generated Solidity library, used to check that calldata is encoded as
+// the library expects to decode it.  It is not the generated library, so changes to the library template must be mirrored here.
+type model struct {
+	saltFunc merkletree.SaltFunc
+	sorted   bool
+}
+
+func (l *model) keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for i := range data {
+		hash.Write(data[i])
+	}
+	return hash.Sum(nil)
+}
+
+func (l *model) hashLeaf(data []byte, index uint64) []byte {
+	if l.saltFunc != nil {
+		return l.keccak256(data, l.saltFunc(index))
+	}
+	return l.keccak256(data)
+}
+
+func (l *model) hashBranch(left []byte, right []byte) []byte {
+	if l.sorted && bytes.Compare(left, right) > 0 {
+		return l.keccak256(right, left)
+	}
+	return l.keccak256(left, right)
+}
+
+func (l *model) call(calldata []byte) (bool, error) {
+	r := &calldataReader{data: calldata[4:]}
+	switch {
+	case bytes.Equal(calldata[:4], encodeCall(verifySignature)):
+		return l.verify(r.word(0), r.bytes(0, 32), r.uint(64), r.words(0, 96)), nil
+	case bytes.Equal(calldata[:4], encodeCall(verifyMultiSignature)):
+		return l.verifyMulti(r.word(0), r.bytesArray(0, 32), r.uints(0, 64), r.uint(96), r.uints(0, 128), r.words(0, 160)), nil
+	default:
+		return false, errors.New("unknown function")
+	}
+}
+
+func (l *model) verify(root []byte, data []byte, index uint64, proof [][]byte) bool {
+	hash := l.hashLeaf(data, index)
+	for i := range proof {
+		if l.sorted || (index>>i)&1 == 0 {
+			hash = l.hashBranch(hash, proof[i])
+		} else {
+			hash = l.hashBranch(proof[i], hash)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
+
+type nodes struct {
+	keys   []uint64
+	hashes [][]byte
+	head   int
+	tail   int
+}
+
+func (l *model) pop(a *nodes, b *nodes) (uint64, []byte) {
+	var key uint64
+	var hash []byte
+	if a.head < a.tail && (b.head == b.tail || a.keys[a.head] > b.keys[b.head]) {
+		key, hash = a.keys[a.head], a.hashes[a.head]
+		a.head++
+	} else if b.head < b.tail {
+		key, hash = b.keys[b.head], b.hashes[b.head]
+		b.head++
+	}
+	return key, hash
+}
+
+func (l *model) verifyMulti(root []byte, data [][]byte, indices []uint64, values uint64, proofIndices []uint64, proofHashes [][]byte) bool {
+	if len(data) == 0 || len(data) != len(indices) || len(proofIndices) != len(proofHashes) {
+		return false
+	}
+	total := len(data) + len(proofIndices)
+
+	known := &nodes{keys: make([]uint64, total), hashes: make([][]byte, total), tail: total}
+	li := 0
+	p := 0
+	for i := 0; i < total; i++ {
+		if p == len(proofIndices) || (li < len(data) && values+indices[li] > proofIndices[p]) {
+			if indices[li] >= values {
+				return false
+			}
+			known.keys[i] = values + indices[li]
+			known.hashes[i] = l.hashLeaf(data[li], indices[li])
+			li++
+		} else {
+			known.keys[i] = proofIndices[p]
+			known.hashes[i] = proofHashes[p]
+			p++
+		}
+		if i > 0 && known.keys[i] >= known.keys[i-1] {
+			return false
+		}
+	}
+
+	parents := &nodes{keys: make([]uint64, total), hashes: make([][]byte, total)}
+	for {
+		key, hash := l.pop(known, parents)
+		if key == 1 {
+			return bytes.Equal(hash, root) && known.head == known.tail && parents.head == parents.tail
+		}
+		if key == 0 || key&1 == 0 {
+			return false
+		}
+		siblingKey, sibling := l.pop(known, parents)
+		if siblingKey != key-1 {
+			return false
+		}
+		parents.keys[parents.tail] = key >> 1
+		parents.hashes[parents.tail] = l.hashBranch(sibling, hash)
+		parents.tail++
+	}
+}
+
+func TestCalldata(t *testing.T) {
+	data := make([][]byte, 13)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+
+	saltFuncs := map[string]merkletree.SaltFunc{
+		"none":    nil,
+		"index32": merkletree.IndexSalt32,
+		"index64": merkletree.IndexSalt64,
+	}
+	for saltName, saltFunc := range saltFuncs {
+		for _, sorted := range []bool{false, true} {
+			name := fmt.Sprintf("salt=%s/sorted=%t", saltName, sorted)
+			tree, err := merkletree.NewTree(
+				merkletree.WithData(append([][]byte{}, data...)),
+				merkletree.WithHashType(keccak256.New()),
+				merkletree.WithSaltFunc(saltFunc),
+				merkletree.WithSorted(sorted),
+			)
+			require.NoError(t, err, name)
+			if saltFunc != nil && sorted {
+				_, err = Library("Verifier", tree)
+				require.EqualError(t, err, "sorted trees salted by index are not supported", name)
+
+				continue
+			}
+			lib := &model{saltFunc: saltFunc, sorted: sorted}
+
+			for i := range tree.Data {
+				proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+				require.NoError(t, err, name)
+				calldata, err := ProofCalldata(tree.Root(), tree.Data[i], proof)
+				require.NoError(t, err, name)
+				verified, err := lib.call(calldata)
+				require.NoError(t, err, name)
+				require.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+
+				calldata, err = ProofCalldata(tree.Root(), []byte("bad"), proof)
+				require.NoError(t, err, name)
+				verified, err = lib.call(calldata)
+				require.NoError(t, err, name)
+				require.False(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+			}
+
+			for _, indices := range [][]uint64{{0}, {12}, {1, 4, 5, 12}, {12, 3, 7}, {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}} {
+				multiProof, err := tree.GenerateMultiProofWithIndices(indices)
+				require.NoError(t, err, name)
+				proofData := make([][]byte, len(indices))
+				for i, index := range indices {
+					proofData[i] = tree.Data[index]
+				}
+				calldata, err := MultiProofCalldata(tree.Root(), proofData, multiProof)
+				require.NoError(t, err, name)
+
+				verified, err := multiProof.Verify(proofData, tree.Root())
+				require.NoError(t, err, name)
+				require.True(t, verified, name)
+
+				verified, err = lib.call(calldata)
+				require.NoError(t, err, name)
+				require.True(t, verified, fmt.Sprintf("%s/indices=%v", name, indices))
+
+				// Verification does not alter the multiproof.
+				verifiedCalldata, err := MultiProofCalldata(tree.Root(), proofData, multiProof)
+				require.NoError(t, err, name)
+				require.Equal(t, calldata, verifiedCalldata, name)
+
+				proofData[0] = []byte("bad")
+				calldata, err = MultiProofCalldata(tree.Root(), proofData, multiProof)
+				require.NoError(t, err, name)
+				verified, err = lib.call(calldata)
+				require.NoError(t, err, name)
+				require.False(t, verified, fmt.Sprintf("%s/indices=%v", name, indices))
+			}
+		}
+	}
+}
+
+func TestCalldataErrors(t *testing.T) {
+	tree, err := merkletree.NewTree(
+		merkletree.WithData([][]byte{[]byte("Foo"), []byte("Bar")}),
+		merkletree.WithHashType(keccak256.New()),
+	)
+	require.NoError(t, err)
+
+	_, err = ProofCalldata(nil, []byte("Foo"), &merkletree.Proof{})
+	require.EqualError(t, err, "root must be 32 bytes")
+	_, err = ProofCalldata(tree.Root(), []byte("Foo"), nil)
+	require.EqualError(t, err, "no proof specified")
+
+	multiProof, err := tree.GenerateMultiProofWithIndices([]uint64{0, 0})
+	require.NoError(t, err)
+	_, err = MultiProofCalldata(tree.Root(), [][]byte{[]byte("Foo")}, multiProof)
+	require.EqualError(t, err, "data and indices must be the same length")
+	_, err = MultiProofCalldata(tree.Root(), [][]byte{[]byte("Foo"), []byte("Foo")}, multiProof)
+	require.EqualError(t, err, "duplicate index")
+
+	_, err = ProofCalldata(tree.Root(), []byte("Foo"), &merkletree.Proof{Count: 2})
+	require.EqualError(t, err, "proofs with a mixed-in count are not supported")
+	_, err = ProofCalldata(tree.Root(), []byte("Foo"), &merkletree.Proof{Arity: 4})
+	require.EqualError(t, err, "proofs with an arity other than 2 are not supported")
+	_, err = MultiProofCalldata(tree.Root(), [][]byte{[]byte("Foo")}, &merkletree.MultiProof{Indices: []uint64{0}, Count: 2})
+	require.EqualError(t, err, "proofs with a mixed-in count are not supported")
+	_, err = MultiProofCalldata(tree.Root(), [][]byte{[]byte("Foo")}, &merkletree.MultiProof{Indices: []uint64{0}, Arity: 4})
+	require.EqualError(t, err, "proofs with an arity other than 2 are not supported")
+}
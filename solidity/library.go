@@ -0,0 +1,222 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package solidity generates Solidity libraries that verify proofs of Merkle trees on-chain, and the calldata to call them.
+//
+// Generated libraries are specific to the configuration of a tree: they only support Keccak-256 hashes, and hash branches
+// either positionally or sorted, and leaves either salted or unsalted, as per the tree.
+package solidity
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"text/template"
+
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+)
+
+// identifierRegex matches valid Solidity identifiers.
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Library generates the source of a Solidity library that verifies proofs and multiproofs for the given tree.
+// The library provides two public functions:
+//
+//	verify(bytes32 root, bytes data, uint256 index, bytes32[] proof) returns (bool)
+//	verifyMulti(bytes32 root, bytes[] data, uint256[] indices, uint256 values, uint256[] proofIndices, bytes32[] proofHashes) returns (bool)
+//
+// with calldata for each generated by ProofCalldata() and MultiProofCalldata() respectively.
+func Library(name string, tree *merkletree.MerkleTree) (string, error) {
+	if !identifierRegex.MatchString(name) {
+		return "", errors.New("invalid library name")
+	}
+	if tree == nil {
+		return "", errors.New("no tree specified")
+	}
+	if tree.Hash == nil || tree.Hash.HashName() != "keccak256" {
+		return "", errors.New("only keccak256 trees are supported")
+	}
+
+	if tree.CountMixIn {
+		return "", errors.New("trees with a mixed-in count are not supported")
+	}
+	if tree.Padding == merkletree.PaddingNone {
+		return "", errors.New("unbalanced trees are not supported")
+	}
+	if tree.Arity != 0 && tree.Arity != 2 {
+		return "", errors.New("trees with an arity other than 2 are not supported")
+	}
+	if tree.Salts != nil {
+		return "", errors.New("trees with per-value salts are not supported")
+	}
+	if tree.Salt && tree.Sorted {
+		// Values are salted with their index before they are sorted, which the library cannot recreate from the sorted index.
+		return "", errors.New("sorted trees salted by index are not supported")
+	}
+
+	saltType := ""
+	if tree.Salt {
+		switch {
+		case tree.SaltFunc == nil || sameFunc(tree.SaltFunc, merkletree.IndexSalt32):
+			saltType = "uint32"
+		case sameFunc(tree.SaltFunc, merkletree.IndexSalt64):
+			saltType = "uint64"
+		default:
+			return "", errors.New("only index salts are supported")
+		}
+	}
+
+	var builder bytes.Buffer
+	if err := libraryTemplate.Execute(&builder, struct {
+		Name     string
+		Salt     bool
+		SaltType string
+		Sorted   bool
+	}{
+		Name:     name,
+		Salt:     tree.Salt,
+		SaltType: saltType,
+		Sorted:   tree.Sorted,
+	}); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
+
+// sameFunc returns true if the two salt functions are the same function.
+func sameFunc(a merkletree.SaltFunc, b merkletree.SaltFunc) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+var libraryTemplate = template.Must(template.New("library").Parse(`// SPDX-License-Identifier: Apache-2.0
+pragma solidity ^0.8.0;
+
+/// @title {{.Name}}
+/// @notice Verifies proofs of a Merkle tree with {{if .Salt}}salted{{else}}unsalted{{end}} leaves and {{if .Sorted}}sorted{{else}}positional{{end}} branches.
+/// @dev Generated by github.com/wealdtech/go-merkletree/v2/solidity.
+library {{.Name}} {
+    struct Nodes {
+        uint256[] keys;
+        bytes32[] hashes;
+        uint256 head;
+        uint256 tail;
+    }
+
+    /// @notice Verifies a proof for data at the given index against the root.
+    function verify(bytes32 root, bytes calldata data, uint256 index, bytes32[] calldata proof) public pure returns (bool) {
+        bytes32 hash = _hashLeaf(data, index);
+        for (uint256 i = 0; i < proof.length; i++) {
+{{- if .Sorted}}
+            hash = _hashBranch(hash, proof[i]);
+{{- else}}
+            if ((index >> i) & 1 == 0) {
+                hash = _hashBranch(hash, proof[i]);
+            } else {
+                hash = _hashBranch(proof[i], hash);
+            }
+{{- end}}
+        }
+        return hash == root;
+    }
+
+    /// @notice Verifies a multiproof for data at the given indices against the root.
+    /// @dev Data and proof hashes must be in strictly descending order of their generalized indices, where the generalized index
+    /// of data is values + index.
+    function verifyMulti(
+        bytes32 root,
+        bytes[] calldata data,
+        uint256[] calldata indices,
+        uint256 values,
+        uint256[] calldata proofIndices,
+        bytes32[] calldata proofHashes
+    ) public pure returns (bool) {
+        if (data.length == 0 || data.length != indices.length || proofIndices.length != proofHashes.length) {
+            return false;
+        }
+        uint256 total = data.length + proofIndices.length;
+
+        // Merge the leaves and proof hashes in to a single list in descending order of generalized index.
+        Nodes memory known = Nodes(new uint256[](total), new bytes32[](total), 0, total);
+        uint256 l = 0;
+        uint256 p = 0;
+        for (uint256 i = 0; i < total; i++) {
+            if (p == proofIndices.length || (l < data.length && values + indices[l] > proofIndices[p])) {
+                if (indices[l] >= values) {
+                    return false;
+                }
+                known.keys[i] = values + indices[l];
+                known.hashes[i] = _hashLeaf(data[l], indices[l]);
+                l++;
+            } else {
+                known.keys[i] = proofIndices[p];
+                known.hashes[i] = proofHashes[p];
+                p++;
+            }
+            if (i > 0 && known.keys[i] >= known.keys[i - 1]) {
+                return false;
+            }
+        }
+
+        // Combine siblings, highest generalized index first, until the root is reached.
+        Nodes memory parents = Nodes(new uint256[](total), new bytes32[](total), 0, 0);
+        while (true) {
+            (uint256 key, bytes32 hash) = _pop(known, parents);
+            if (key == 1) {
+                return hash == root && known.head == known.tail && parents.head == parents.tail;
+            }
+            if (key == 0 || key & 1 == 0) {
+                return false;
+            }
+            (uint256 siblingKey, bytes32 sibling) = _pop(known, parents);
+            if (siblingKey != key - 1) {
+                return false;
+            }
+            parents.keys[parents.tail] = key >> 1;
+            parents.hashes[parents.tail] = _hashBranch(sibling, hash);
+            parents.tail++;
+        }
+    }
+
+    /// @dev Removes and returns the node with the highest generalized index from either list, or a key of 0 if both are empty.
+    function _pop(Nodes memory a, Nodes memory b) private pure returns (uint256 key, bytes32 hash) {
+        if (a.head < a.tail && (b.head == b.tail || a.keys[a.head] > b.keys[b.head])) {
+            key = a.keys[a.head];
+            hash = a.hashes[a.head];
+            a.head++;
+        } else if (b.head < b.tail) {
+            key = b.keys[b.head];
+            hash = b.hashes[b.head];
+            b.head++;
+        }
+    }
+{{if .Salt}}
+    function _hashLeaf(bytes calldata data, uint256 index) private pure returns (bytes32) {
+        return keccak256(abi.encodePacked(data, {{.SaltType}}(index)));
+    }
+{{- else}}
+    function _hashLeaf(bytes calldata data, uint256) private pure returns (bytes32) {
+        return keccak256(data);
+    }
+{{- end}}
+
+    function _hashBranch(bytes32 left, bytes32 right) private pure returns (bytes32) {
+{{- if .Sorted}}
+        return left <= right ? keccak256(abi.encodePacked(left, right)) : keccak256(abi.encodePacked(right, left));
+{{- else}}
+        return keccak256(abi.encodePacked(left, right));
+{{- end}}
+    }
+}
+`))
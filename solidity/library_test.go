@@ -0,0 +1,199 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solidity_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/sha3"
+	"github.com/wealdtech/go-merkletree/v2/solidity"
+)
+
+func TestLibrary(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}
+
+	tests := []struct {
+		saltFunc merkletree.SaltFunc
+		sorted   bool
+		contains []string
+		excludes []string
+	}{
+		{
+			contains: []string{
+				"unsalted leaves and positional branches",
+				"return keccak256(data);",
+				"if ((index >> i) & 1 == 0) {",
+				"return keccak256(abi.encodePacked(left, right));",
+			},
+			excludes: []string{"uint32(index)", "left <= right"},
+		},
+		{
+			saltFunc: merkletree.IndexSalt32,
+			contains: []string{
+				"salted leaves and positional branches",
+				"return keccak256(abi.encodePacked(data, uint32(index)));",
+				"if ((index >> i) & 1 == 0) {",
+			},
+			excludes: []string{"left <= right"},
+		},
+		{
+			saltFunc: merkletree.IndexSalt64,
+			contains: []string{
+				"salted leaves and positional branches",
+				"return keccak256(abi.encodePacked(data, uint64(index)));",
+			},
+			excludes: []string{"uint32(index)"},
+		},
+		{
+			sorted: true,
+			contains: []string{
+				"unsalted leaves and sorted branches",
+				"return left <= right ? keccak256(abi.encodePacked(left, right)) : keccak256(abi.encodePacked(right, left));",
+			},
+			excludes: []string{"uint32(index)", "(index >> i)"},
+		},
+	}
+
+	for i, test := range tests {
+		tree, err := merkletree.NewTree(
+			merkletree.WithData(data),
+			merkletree.WithHashType(keccak256.New()),
+			merkletree.WithSaltFunc(test.saltFunc),
+			merkletree.WithSorted(test.sorted),
+		)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		source, err := solidity.Library("MerkleVerifier", tree)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+		assert.Contains(t, source, "library MerkleVerifier {", fmt.Sprintf("failed at test %d", i))
+		assert.Contains(t, source, "function verify(bytes32 root, bytes calldata data, uint256 index, bytes32[] calldata proof) public pure returns (bool) {", fmt.Sprintf("failed at test %d", i))
+		assert.Contains(t, source, "function verifyMulti(", fmt.Sprintf("failed at test %d", i))
+		for _, contains := range test.contains {
+			assert.Contains(t, source, contains, fmt.Sprintf("failed at test %d", i))
+		}
+		for _, excludes := range test.excludes {
+			assert.NotContains(t, source, excludes, fmt.Sprintf("failed at test %d", i))
+		}
+	}
+}
+
+func TestLibraryErrors(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar")}
+	tree, err := merkletree.NewTree(merkletree.WithData(data), merkletree.WithHashType(keccak256.New()))
+	require.NoError(t, err)
+	sha256Tree, err := merkletree.NewTree(merkletree.WithData(data), merkletree.WithHashType(sha3.New256()))
+	require.NoError(t, err)
+	unbalancedTree, err := merkletree.NewTree(
+		merkletree.WithData([][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz")}),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithPadding(merkletree.PaddingNone),
+	)
+	require.NoError(t, err)
+	countTree, err := merkletree.NewTree(
+		merkletree.WithData(data),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithCountMixIn(true),
+	)
+	require.NoError(t, err)
+	kAryTree, err := merkletree.NewTree(
+		merkletree.WithData(data),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithArity(4),
+	)
+	require.NoError(t, err)
+	customSaltTree, err := merkletree.NewTree(
+		merkletree.WithData(data),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithSaltFunc(func(index uint64) []byte { return []byte{byte(index)} }),
+	)
+	require.NoError(t, err)
+	sortedSaltTree, err := merkletree.NewTree(
+		merkletree.WithData(data),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithSalt(true),
+		merkletree.WithSorted(true),
+	)
+	require.NoError(t, err)
+	randomSaltTree, err := merkletree.NewTree(
+		merkletree.WithData(data),
+		merkletree.WithHashType(keccak256.New()),
+		merkletree.WithRandomSalts(true),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		tree *merkletree.MerkleTree
+		err  string
+	}{
+		{
+			name: "",
+			tree: tree,
+			err:  "invalid library name",
+		},
+		{
+			name: "1Verifier",
+			tree: tree,
+			err:  "invalid library name",
+		},
+		{
+			name: "Verifier",
+			err:  "no tree specified",
+		},
+		{
+			name: "Verifier",
+			tree: sha256Tree,
+			err:  "only keccak256 trees are supported",
+		},
+		{
+			name: "Verifier",
+			tree: countTree,
+			err:  "trees with a mixed-in count are not supported",
+		},
+		{
+			name: "Verifier",
+			tree: unbalancedTree,
+			err:  "unbalanced trees are not supported",
+		},
+		{
+			name: "Verifier",
+			tree: kAryTree,
+			err:  "trees with an arity other than 2 are not supported",
+		},
+		{
+			name: "Verifier",
+			tree: customSaltTree,
+			err:  "only index salts are supported",
+		},
+		{
+			name: "Verifier",
+			tree: randomSaltTree,
+			err:  "trees with per-value salts are not supported",
+		},
+		{
+			name: "Verifier",
+			tree: sortedSaltTree,
+			err:  "sorted trees salted by index are not supported",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := solidity.Library(test.name, test.tree)
+		assert.EqualError(t, err, test.err, fmt.Sprintf("failed at test %d", i))
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// NewTreeFromSubtrees creates the top levels of a merkle tree from subtrees over consecutive slices of its data, for example
+// those built by separate workers.  The leaves of the resultant tree are the roots of the subtrees, and its root is the same as
+// that of a tree created from all of the data with the same parameters.
+//
+// All subtrees must have the same hash type, arity, padding and number of leaves, and all but the last must be full.  The last
+// subtree can be given the same number of leaves as the others with WithWidth().  Subtrees cannot be sorted, salted with their
+// index or mix in their count.
+// Proofs from a subtree and from the resultant tree can be combined with ComposeProofs().
+func NewTreeFromSubtrees(subtrees []*MerkleTree) (*MerkleTree, error) {
+	if len(subtrees) == 0 {
+		return nil, errors.New("no subtrees specified")
+	}
+	first := subtrees[0]
+	for i, subtree := range subtrees {
+		if subtree == nil {
+			return nil, fmt.Errorf("missing subtree at index %d", i)
+		}
+		if subtree.Sorted {
+			return nil, errors.New("sorted subtrees are not supported")
+		}
+		if subtree.Salt {
+			return nil, errors.New("index salted subtrees are not supported")
+		}
+		if subtree.CountMixIn {
+			return nil, errors.New("subtrees with a mixed-in count are not supported")
+		}
+		if subtree.Hash.HashName() != first.Hash.HashName() ||
+			subtree.arity() != first.arity() ||
+			subtree.Padding != first.Padding ||
+			len(subtree.Nodes) != len(first.Nodes) {
+			return nil, fmt.Errorf("subtree at index %d does not match the first subtree", i)
+		}
+		if i < len(subtrees)-1 && subtree.values() != uint64(len(subtree.Nodes))-subtree.leafOffset() {
+			return nil, fmt.Errorf("subtree at index %d is not full", i)
+		}
+	}
+
+	roots := make([][]byte, len(subtrees))
+	for i := range subtrees {
+		roots[i] = subtrees[i].Nodes[1]
+	}
+	last := subtrees[len(subtrees)-1]
+	lastLeaf := last.Nodes[last.leafOffset()+last.values()-1]
+
+	return newTreeFromRoots(roots,
+		depthFor(uint64(len(first.Nodes))-first.leafOffset(), first.arity()),
+		first.Hash,
+		first.Arity,
+		first.Padding,
+		paddingLeaf(first.Padding, first.Hash, lastLeaf),
+	)
+}
+
+// NewTreeFromSubtreeRoots creates the top levels of a merkle tree from the roots of subtrees over consecutive slices of its
+// data, each with leaves to the given height.  This is the same as NewTreeFromSubtrees() but does not require the subtrees.
+//
+// The hash type, arity and padding should be supplied as parameters, and must be the same as those of the subtrees.  As the
+// subtrees are not available their last leaf is unknown, so PaddingDuplicateLast is not supported.
+func NewTreeFromSubtreeRoots(roots [][]byte, height int, params ...Parameter) (*MerkleTree, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("no subtree roots specified")
+	}
+	parameters, err := parseAndCheckTreeParameters(append(params, WithLeafHashes(roots))...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+	if parameters.sorted {
+		return nil, errors.New("sorted trees cannot be built from subtrees")
+	}
+	if parameters.countMixIn {
+		return nil, errors.New("trees built from subtrees cannot mix in their count")
+	}
+	if parameters.padding == PaddingDuplicateLast {
+		return nil, errors.New("duplicate-last padding requires the subtrees")
+	}
+	if height < 0 {
+		return nil, errors.New("height cannot be negative")
+	}
+
+	return newTreeFromRoots(roots,
+		height,
+		parameters.hash,
+		parameters.arity,
+		parameters.padding,
+		paddingLeaf(parameters.padding, parameters.hash, nil),
+	)
+}
+
+// newTreeFromRoots creates a tree with the subtree roots as its leaves, padded with the root of a subtree of the given height
+// in which all leaves are the padding leaf.
+func newTreeFromRoots(roots [][]byte, height int, hashType HashType, arity int, padding Padding, pad []byte) (*MerkleTree, error) {
+	params := []Parameter{
+		WithHashType(hashType),
+		WithArity(arity),
+	}
+	if padding == PaddingNone {
+		// Empty subtrees are also empty in the top levels of the tree.
+		params = append(params, WithLeafHashes(roots), WithPadding(PaddingNone))
+	} else {
+		k := arityOf(arity)
+		depth := depthFor(uint64(len(roots)), k)
+		leaves := make([][]byte, levelStart(depth+1, k)-levelStart(depth, k))
+		copy(leaves, roots)
+		emptyRoot := emptySubtreeRoot(newHasher(hashType), pad, height, k)
+		for i := len(roots); i < len(leaves); i++ {
+			leaves[i] = emptyRoot
+		}
+		params = append(params, WithLeafHashes(leaves))
+	}
+
+	tree, err := NewTree(params...)
+	if err != nil {
+		return nil, err
+	}
+	tree.Count = uint64(len(roots))
+	tree.Padding = padding
+
+	return tree, nil
+}
+
+// emptySubtreeRoot returns the root of a subtree of the given height in which all leaves are the padding leaf.
+func emptySubtreeRoot(hasher *hasher, pad []byte, height int, arity uint64) []byte {
+	root := pad
+	children := make([][]byte, arity)
+	for i := 0; i < height; i++ {
+		for j := range children {
+			children[j] = root
+		}
+		root = hasher.hashChildren(nil, children)
+	}
+
+	return root
+}
+
+// ComposeProofs combines a proof of a value in a subtree with a proof of the root of that subtree in a tree created by
+// NewTreeFromSubtrees() or NewTreeFromSubtreeRoots(), resulting in a proof of the value against the root of the full tree.
+// The lower proof must be to the root of the subtree, i.e. generated with a height of 0.
+func ComposeProofs(lower *Proof, upper *Proof) (*Proof, error) {
+	if lower == nil || upper == nil {
+		return nil, errors.New("no proof specified")
+	}
+	if lower.arity() != upper.arity() {
+		return nil, errors.New("proofs have different arities")
+	}
+	if lower.Count != 0 {
+		return nil, errors.New("lower proof cannot have a count")
+	}
+	if !lower.valid() || !upper.valid() {
+		return nil, errors.New("invalid proof")
+	}
+
+	arity := lower.arity()
+	width := uint64(1)
+	for i := 0; i < len(lower.Hashes)/int(arity-1); i++ {
+		width *= arity
+	}
+	if lower.Index >= width {
+		return nil, errors.New("lower proof index out of range")
+	}
+
+	hashes := make([][]byte, 0, len(lower.Hashes)+len(upper.Hashes))
+	hashes = append(hashes, lower.Hashes...)
+	hashes = append(hashes, upper.Hashes...)
+
+	return &Proof{
+		Hashes: hashes,
+		Index:  upper.Index*width + lower.Index,
+		Count:  upper.Count,
+		Arity:  upper.Arity,
+	}, nil
+}
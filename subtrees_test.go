@@ -0,0 +1,187 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+// buildSubtrees builds subtrees of the given size over the data.
+func buildSubtrees(t *testing.T, data [][]byte, size int, params ...Parameter) []*MerkleTree {
+	t.Helper()
+
+	subtrees := make([]*MerkleTree, 0)
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		subtree, err := NewTree(append([]Parameter{WithData(data[start:end])}, params...)...)
+		require.NoError(t, err)
+		subtrees = append(subtrees, subtree)
+	}
+
+	return subtrees
+}
+
+func TestNewTreeFromSubtrees(t *testing.T) {
+	hashType := keccak256.New()
+	for _, padding := range []Padding{PaddingZeroHash, PaddingEmptyHash, PaddingDuplicateLast, PaddingNone} {
+		for _, arity := range []int{2, 4} {
+			if arity != 2 && padding == PaddingNone {
+				continue
+			}
+			for _, values := range []int{1, 4, 13, 16, 17, 40} {
+				name := fmt.Sprintf("padding=%s/arity=%d/values=%d", padding, arity, values)
+				data := make([][]byte, values)
+				for i := range data {
+					data[i] = []byte(fmt.Sprintf("value %d", i))
+				}
+				params := []Parameter{WithHashType(hashType), WithArity(arity), WithPadding(padding)}
+				tree, err := NewTree(append([]Parameter{WithData(data)}, params...)...)
+				require.NoError(t, err, name)
+
+				size := 4
+				if arity == 4 {
+					size = 16
+				}
+				subtreeParams := params
+				if values > size {
+					// The last subtree must have the same shape as the others.
+					subtreeParams = append(subtreeParams, WithWidth(uint64(size)))
+				}
+				subtrees := buildSubtrees(t, data, size, subtreeParams...)
+				top, err := NewTreeFromSubtrees(subtrees)
+				require.NoError(t, err, name)
+				assert.Equal(t, tree.Root(), top.Root(), name)
+				assert.Equal(t, uint64(len(subtrees)), top.Count, name)
+
+				if padding != PaddingDuplicateLast {
+					roots := make([][]byte, len(subtrees))
+					for i := range subtrees {
+						roots[i] = subtrees[i].Root()
+					}
+					rootsTop, err := NewTreeFromSubtreeRoots(roots, depthFor(uint64(len(subtrees[0].Nodes))-subtrees[0].leafOffset(), uint64(arity)), params...)
+					require.NoError(t, err, name)
+					assert.Equal(t, tree.Root(), rootsTop.Root(), name)
+				}
+
+				for i := range data {
+					lower, err := subtrees[i/size].GenerateProofWithIndex(uint64(i%size), 0)
+					require.NoError(t, err, name)
+					upper, err := top.GenerateProofWithIndex(uint64(i/size), 0)
+					require.NoError(t, err, name)
+					proof, err := ComposeProofs(lower, upper)
+					require.NoError(t, err, name)
+					assert.Equal(t, uint64(i), proof.Index, name)
+					verified, err := VerifyProofUsing(data[i], false, proof, [][]byte{tree.Root()}, hashType)
+					require.NoError(t, err, name)
+					assert.True(t, verified, fmt.Sprintf("%s/index=%d", name, i))
+				}
+			}
+		}
+	}
+}
+
+func TestNewTreeFromSubtreesErrors(t *testing.T) {
+	data := [][]byte{[]byte("Foo"), []byte("Bar"), []byte("Baz"), []byte("Qux"), []byte("Quux")}
+	full, err := NewTree(WithData(data[0:4]))
+	require.NoError(t, err)
+	partial, err := NewTree(WithData(data[0:3]))
+	require.NoError(t, err)
+	tiny, err := NewTree(WithData(data[0:2]))
+	require.NoError(t, err)
+	keccakTree, err := NewTree(WithData(data[0:4]), WithHashType(keccak256.New()))
+	require.NoError(t, err)
+	sorted, err := NewTree(WithData(data[0:4]), WithSorted(true))
+	require.NoError(t, err)
+	salted, err := NewTree(WithData(data[0:4]), WithSalt(true))
+	require.NoError(t, err)
+	counted, err := NewTree(WithData(data[0:4]), WithCountMixIn(true))
+	require.NoError(t, err)
+
+	tests := []struct {
+		subtrees []*MerkleTree
+		err      string
+	}{
+		{
+			err: "no subtrees specified",
+		},
+		{
+			subtrees: []*MerkleTree{full, nil},
+			err:      "missing subtree at index 1",
+		},
+		{
+			subtrees: []*MerkleTree{full, keccakTree},
+			err:      "subtree at index 1 does not match the first subtree",
+		},
+		{
+			subtrees: []*MerkleTree{partial, full},
+			err:      "subtree at index 0 is not full",
+		},
+		{
+			subtrees: []*MerkleTree{full, tiny},
+			err:      "subtree at index 1 does not match the first subtree",
+		},
+		{
+			subtrees: []*MerkleTree{sorted},
+			err:      "sorted subtrees are not supported",
+		},
+		{
+			subtrees: []*MerkleTree{salted},
+			err:      "index salted subtrees are not supported",
+		},
+		{
+			subtrees: []*MerkleTree{counted},
+			err:      "subtrees with a mixed-in count are not supported",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := NewTreeFromSubtrees(test.subtrees)
+		assert.EqualError(t, err, test.err, fmt.Sprintf("failed at test %d", i))
+	}
+
+	roots := [][]byte{full.Root()}
+	_, err = NewTreeFromSubtreeRoots(nil, 2)
+	assert.EqualError(t, err, "no subtree roots specified")
+	_, err = NewTreeFromSubtreeRoots(roots, 2, WithSorted(true))
+	assert.EqualError(t, err, "sorted trees cannot be built from subtrees")
+	_, err = NewTreeFromSubtreeRoots(roots, 2, WithCountMixIn(true))
+	assert.EqualError(t, err, "trees built from subtrees cannot mix in their count")
+	_, err = NewTreeFromSubtreeRoots(roots, 2, WithPadding(PaddingDuplicateLast))
+	assert.EqualError(t, err, "duplicate-last padding requires the subtrees")
+	_, err = NewTreeFromSubtreeRoots(roots, -1)
+	assert.EqualError(t, err, "height cannot be negative")
+}
+
+func TestComposeProofsErrors(t *testing.T) {
+	proof := &Proof{Hashes: [][]byte{{0x01}, {0x02}}, Index: 1}
+
+	_, err := ComposeProofs(nil, proof)
+	assert.EqualError(t, err, "no proof specified")
+	_, err = ComposeProofs(proof, &Proof{Arity: 4})
+	assert.EqualError(t, err, "proofs have different arities")
+	_, err = ComposeProofs(&Proof{Hashes: proof.Hashes, Count: 2}, proof)
+	assert.EqualError(t, err, "lower proof cannot have a count")
+	_, err = ComposeProofs(&Proof{Hashes: proof.Hashes, Arity: 3}, &Proof{Arity: 3})
+	assert.EqualError(t, err, "invalid proof")
+	_, err = ComposeProofs(&Proof{Hashes: proof.Hashes, Index: 4}, proof)
+	assert.EqualError(t, err, "lower proof index out of range")
+}
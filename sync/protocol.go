@@ -0,0 +1,63 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sync synchronises the data of Merkle tree replicas with an anti-entropy protocol.  The replicas exchange the hashes of
+// nodes from the root downwards, descending only where the hashes differ, and then fetch the values of the leaves that differ.
+// This allows replicas holding slightly different data to converge with a number of hashes exchanged proportional to the number
+// of differences multiplied by the depth of the tree, rather than to the amount of data held.
+//
+// The replica holding the data to converge on runs a Server, and the other replica calls Sync() with a Transport to reach it.
+package sync
+
+// RequestType is the type of a request.
+type RequestType string
+
+const (
+	// RequestInfo requests information about the remote tree.
+	RequestInfo RequestType = "info"
+	// RequestNodes requests the hashes of the nodes at the given indices of the remote tree.
+	RequestNodes RequestType = "nodes"
+	// RequestValues requests the values at the given indices of the remote tree.
+	RequestValues RequestType = "values"
+)
+
+// Request is a request to a remote replica.
+type Request struct {
+	Type    RequestType `json:"type"`
+	Indices []uint64    `json:"indices,omitempty"`
+}
+
+// Response is the response from a remote replica.
+type Response struct {
+	Info   *Info    `json:"info,omitempty"`
+	Hashes [][]byte `json:"hashes,omitempty"`
+	Values [][]byte `json:"values,omitempty"`
+	// Error is the reason that the request failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Info is information about a tree, used to decide if the nodes of two trees can be compared.
+type Info struct {
+	// HashType is the name of the hash type of the tree.
+	HashType string `json:"hash_type"`
+	// Arity is the number of children of each branch of the tree.
+	Arity int `json:"arity"`
+	// Nodes is the number of nodes in the tree.
+	Nodes uint64 `json:"nodes"`
+	// Count is the number of values in the tree.
+	Count uint64 `json:"count"`
+	// Root is the root of the tree.
+	Root []byte `json:"root"`
+	// Sorted is true if the tree is sorted.
+	Sorted bool `json:"sorted,omitempty"`
+}
@@ -0,0 +1,116 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+)
+
+// Server answers requests from remote replicas about a tree.
+type Server struct {
+	tree *merkletree.MerkleTree
+}
+
+// NewServer creates a new server for the tree.
+func NewServer(tree *merkletree.MerkleTree) (*Server, error) {
+	if tree == nil {
+		return nil, errors.New("no tree specified")
+	}
+
+	return &Server{
+		tree: tree,
+	}, nil
+}
+
+// Handle handles a single request.  Failures are returned in the response rather than as an error, so that they can be passed
+// to the remote replica.
+func (s *Server) Handle(request *Request) *Response {
+	if request == nil {
+		return &Response{Error: "no request specified"}
+	}
+
+	switch request.Type {
+	case RequestInfo:
+		return &Response{Info: s.info()}
+	case RequestNodes:
+		hashes, err := s.tree.NodesAt(request.Indices)
+		if err != nil {
+			return &Response{Error: err.Error()}
+		}
+
+		return &Response{Hashes: hashes}
+	case RequestValues:
+		values, err := s.values(request.Indices)
+		if err != nil {
+			return &Response{Error: err.Error()}
+		}
+
+		return &Response{Values: values}
+	default:
+		return &Response{Error: fmt.Sprintf("unknown request type %q", request.Type)}
+	}
+}
+
+// Serve handles requests from the stream until it is closed.
+// Requests and responses are JSON-encoded, as per NewStreamTransport().
+func (s *Server) Serve(rw io.ReadWriter) error {
+	decoder := json.NewDecoder(rw)
+	for {
+		var request Request
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+		if err := writeMessage(rw, s.Handle(&request)); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+}
+
+// info returns information about the tree.
+func (s *Server) info() *Info {
+	return &Info{
+		HashType: s.tree.Hash.HashName(),
+		Arity:    arityOf(s.tree.Arity),
+		Nodes:    uint64(len(s.tree.Nodes)),
+		Count:    countOf(s.tree),
+		Root:     s.tree.Root(),
+		Sorted:   s.tree.Sorted,
+	}
+}
+
+// values returns the values at the given indices of the tree.
+func (s *Server) values(indices []uint64) ([][]byte, error) {
+	if s.tree.Data == nil {
+		return nil, errors.New("tree has no data")
+	}
+
+	values := make([][]byte, len(indices))
+	for i, index := range indices {
+		if index >= uint64(len(s.tree.Data)) {
+			return nil, errors.New("index out of range")
+		}
+		values[i] = s.tree.Data[index]
+	}
+
+	return values, nil
+}
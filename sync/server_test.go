@@ -0,0 +1,103 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/sync"
+)
+
+func TestServerHandle(t *testing.T) {
+	tree, err := merkletree.NewTree(merkletree.WithData(syncData(5)), merkletree.WithArity(4))
+	require.NoError(t, err)
+	server, err := sync.NewServer(tree)
+	require.NoError(t, err)
+
+	tests := []struct {
+		request  *sync.Request
+		response *sync.Response
+	}{
+		{
+			response: &sync.Response{Error: "no request specified"},
+		},
+		{
+			request:  &sync.Request{Type: "unknown"},
+			response: &sync.Response{Error: `unknown request type "unknown"`},
+		},
+		{
+			request:  &sync.Request{Type: sync.RequestInfo},
+			response: &sync.Response{Info: &sync.Info{HashType: "blake2b", Arity: 4, Nodes: 22, Count: 5, Root: tree.Root()}},
+		},
+		{
+			request:  &sync.Request{Type: sync.RequestNodes, Indices: []uint64{1, 21}},
+			response: &sync.Response{Hashes: [][]byte{tree.Nodes[1], tree.Nodes[21]}},
+		},
+		{
+			request:  &sync.Request{Type: sync.RequestNodes, Indices: []uint64{22}},
+			response: &sync.Response{Error: "index out of range"},
+		},
+		{
+			request:  &sync.Request{Type: sync.RequestValues, Indices: []uint64{4, 0}},
+			response: &sync.Response{Values: [][]byte{tree.Data[4], tree.Data[0]}},
+		},
+		{
+			request:  &sync.Request{Type: sync.RequestValues, Indices: []uint64{5}},
+			response: &sync.Response{Error: "index out of range"},
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.response, server.Handle(test.request), fmt.Sprintf("failed at test %d", i))
+	}
+
+	_, err = sync.NewServer(nil)
+	assert.EqualError(t, err, "no tree specified")
+}
+
+func TestServerServe(t *testing.T) {
+	tree, err := merkletree.NewTree(merkletree.WithData(syncData(5)))
+	require.NoError(t, err)
+	server, err := sync.NewServer(tree)
+	require.NoError(t, err)
+
+	in := bytes.NewBufferString(`{"type":"info"}{"type":"values","indices":[1]}`)
+	out := new(bytes.Buffer)
+	require.NoError(t, server.Serve(&readWriter{reader: in, writer: out}))
+	expected := fmt.Sprintf(`{"info":{"hash_type":"blake2b","arity":2,"nodes":16,"count":5,"root":%q}}{"values":["dmFsdWUgMQ=="]}`,
+		base64.StdEncoding.EncodeToString(tree.Root()))
+	assert.Equal(t, expected, out.String())
+
+	err = server.Serve(&readWriter{reader: bytes.NewBufferString("{"), writer: out})
+	assert.EqualError(t, err, "failed to read request: unexpected EOF")
+}
+
+type readWriter struct {
+	reader *bytes.Buffer
+	writer *bytes.Buffer
+}
+
+func (rw *readWriter) Read(p []byte) (int, error) {
+	return rw.reader.Read(p)
+}
+
+func (rw *readWriter) Write(p []byte) (int, error) {
+	return rw.writer.Write(p)
+}
@@ -0,0 +1,292 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+)
+
+// _valuesBatchSize is the number of values requested at a time when all values of the remote tree are fetched.
+const _valuesBatchSize = 1024
+
+// Result is the result of synchronising with a remote replica.
+type Result struct {
+	// Count is the number of values in the remote tree.
+	Count uint64
+	// Root is the root of the remote tree.
+	Root []byte
+	// Indices are the indices of the values that differ from the remote tree, in ascending order.
+	Indices []uint64
+	// Values are the values of the remote tree at the indices.
+	Values [][]byte
+	// Requests is the number of requests made to the remote replica.
+	Requests int
+}
+
+// Sync finds the values of the local tree that differ from those of the remote tree.
+//
+// If the trees have the same shape, that is the same hash type, arity and number of nodes, their nodes are compared from the
+// root downwards and only the values of differing leaves are fetched.  Otherwise all values of the remote tree are fetched, in
+// batches.
+// Sorted trees, trees with per-value salts and trees created from leaf hashes are not supported.
+func Sync(local *merkletree.MerkleTree, transport Transport) (*Result, error) {
+	if local == nil {
+		return nil, errors.New("no tree specified")
+	}
+	if transport == nil {
+		return nil, errors.New("no transport specified")
+	}
+	if err := checkTree(local); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	response, err := result.roundTrip(transport, &Request{Type: RequestInfo})
+	if err != nil {
+		return nil, err
+	}
+	info := response.Info
+	if info == nil {
+		return nil, errors.New("remote did not return info")
+	}
+	if info.HashType != local.Hash.HashName() {
+		return nil, errors.New("replicas use different hash types")
+	}
+	if info.Sorted {
+		return nil, errors.New("sorted trees are not supported")
+	}
+	if err := checkInfo(info); err != nil {
+		return nil, err
+	}
+	if len(info.Root) != local.Hash.HashLength() {
+		return nil, errors.New("remote tree has an invalid root")
+	}
+	result.Count = info.Count
+	result.Root = info.Root
+
+	localCount := uint64(len(local.Data))
+	if info.Arity == arityOf(local.Arity) && info.Nodes == uint64(len(local.Nodes)) {
+		diffs, err := merkletree.DiffRemote(local, func(indices []uint64) ([][]byte, error) {
+			response, err := result.roundTrip(transport, &Request{Type: RequestNodes, Indices: indices})
+			if err != nil {
+				return nil, err
+			}
+
+			return response.Hashes, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range diffs {
+			if index < localCount && index < info.Count {
+				result.Indices = append(result.Indices, index)
+			}
+		}
+		// Values beyond the end of the local tree are always required, as they can match its padding.
+		for index := localCount; index < info.Count; index++ {
+			result.Indices = append(result.Indices, index)
+		}
+		if err := result.fetchValues(transport, result.Indices); err != nil {
+			return nil, err
+		}
+	} else {
+		// The remote tree can be of any size, so values are fetched in batches to allocate memory only as they are received.
+		for first := uint64(0); first < info.Count; first += _valuesBatchSize {
+			batch := info.Count - first
+			if batch > _valuesBatchSize {
+				batch = _valuesBatchSize
+			}
+			indices := make([]uint64, batch)
+			for i := range indices {
+				indices[i] = first + uint64(i)
+			}
+			result.Indices = append(result.Indices, indices...)
+			if err := result.fetchValues(transport, indices); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Apply creates a tree from the data of the local tree with the differences from the remote tree applied.  The new tree has the
+// same configuration as the local tree, which must also be that of the remote tree, as an error is returned if the root of the
+// new tree is not that of the remote tree.
+func (r *Result) Apply(local *merkletree.MerkleTree) (*merkletree.MerkleTree, error) {
+	if local == nil {
+		return nil, errors.New("no tree specified")
+	}
+	if err := checkTree(local); err != nil {
+		return nil, err
+	}
+	if len(r.Values) != len(r.Indices) {
+		return nil, errors.New("indices and values must be the same length")
+	}
+	if r.Count == 0 {
+		return nil, errors.New("remote tree has no values")
+	}
+	if r.Count > uint64(len(local.Data))+uint64(len(r.Indices)) {
+		return nil, errors.New("count exceeds the local and remote values")
+	}
+
+	data := make([][]byte, r.Count)
+	copy(data, local.Data)
+	// Values beyond the end of the local tree must all have been fetched.
+	localCount := uint64(len(local.Data))
+	if localCount > r.Count {
+		localCount = r.Count
+	}
+	fetched := make([]bool, r.Count-localCount)
+	for i, index := range r.Indices {
+		if index >= r.Count {
+			return nil, errors.New("index out of range")
+		}
+		data[index] = r.Values[i]
+		if index >= localCount {
+			fetched[index-localCount] = true
+		}
+	}
+	for i := range fetched {
+		if !fetched[i] {
+			return nil, fmt.Errorf("missing value at index %d", localCount+uint64(i))
+		}
+	}
+
+	params := []merkletree.Parameter{
+		merkletree.WithData(data),
+		merkletree.WithHashType(local.Hash),
+		merkletree.WithPadding(local.Padding),
+		merkletree.WithArity(local.Arity),
+		merkletree.WithCountMixIn(local.CountMixIn),
+	}
+	if local.Salt {
+		params = append(params, merkletree.WithSalt(true), merkletree.WithSaltFunc(local.SaltFunc))
+	}
+
+	tree, err := merkletree.NewTree(params...)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(tree.Root(), r.Root) {
+		return nil, errors.New("root does not match remote root")
+	}
+
+	return tree, nil
+}
+
+// fetchValues fetches the values at the indices from the remote replica, adding them to the result.
+func (r *Result) fetchValues(transport Transport, indices []uint64) error {
+	if len(indices) == 0 {
+		return nil
+	}
+	response, err := r.roundTrip(transport, &Request{Type: RequestValues, Indices: indices})
+	if err != nil {
+		return err
+	}
+	if len(response.Values) != len(indices) {
+		return errors.New("incorrect number of remote values")
+	}
+	r.Values = append(r.Values, response.Values...)
+
+	return nil
+}
+
+// roundTrip sends a request to the remote replica, returning an error if the remote replica failed to handle it.
+func (r *Result) roundTrip(transport Transport, request *Request) (*Response, error) {
+	r.Requests++
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("remote error: %s", response.Error)
+	}
+
+	return response, nil
+}
+
+// checkTree checks that the tree can be synchronised.
+func checkTree(tree *merkletree.MerkleTree) error {
+	if tree.Data == nil {
+		return errors.New("trees created from leaf hashes are not supported")
+	}
+	if tree.Sorted {
+		return errors.New("sorted trees are not supported")
+	}
+	if tree.Salts != nil {
+		return errors.New("trees with per-value salts are not supported")
+	}
+
+	return nil
+}
+
+// checkInfo checks that the information about the remote tree describes a valid tree, so that its count can be trusted when
+// making requests and allocating memory.
+func checkInfo(info *Info) error {
+	switch info.Arity {
+	case 2, 4, 8, 16:
+	default:
+		return errors.New("remote tree has an invalid arity")
+	}
+	width := leafWidth(info.Nodes, uint64(info.Arity))
+	if width == 0 {
+		return errors.New("remote tree has an invalid number of nodes")
+	}
+	if info.Count == 0 || info.Count > width {
+		return errors.New("remote tree has an invalid count")
+	}
+
+	return nil
+}
+
+// leafWidth returns the number of leaves of a tree with the given number of nodes and arity, or 0 if a tree cannot have that
+// number of nodes.  Node 0 of a tree is unused, and its root is node 1.
+func leafWidth(nodes uint64, arity uint64) uint64 {
+	start := uint64(1)
+	for width := uint64(1); start <= nodes; width *= arity {
+		if nodes-start == width {
+			return width
+		}
+		if width > math.MaxUint64/arity {
+			break
+		}
+		start += width
+	}
+
+	return 0
+}
+
+// arityOf returns the arity of a tree, treating 0 as binary.
+func arityOf(arity int) int {
+	if arity == 0 {
+		return 2
+	}
+
+	return arity
+}
+
+// countOf returns the number of values in the tree.
+func countOf(tree *merkletree.MerkleTree) uint64 {
+	if tree.Count == 0 {
+		return uint64(len(tree.Data))
+	}
+
+	return tree.Count
+}
@@ -0,0 +1,307 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	merkletree "github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+	"github.com/wealdtech/go-merkletree/v2/sync"
+)
+
+func syncData(values int, changes ...int) [][]byte {
+	data := make([][]byte, values)
+	for i := range data {
+		data[i] = []byte(fmt.Sprintf("value %d", i))
+	}
+	for _, change := range changes {
+		data[change] = []byte(fmt.Sprintf("changed value %d", change))
+	}
+
+	return data
+}
+
+// streamTransport creates a transport to a server over a network connection.
+func streamTransport(t *testing.T, server *sync.Server) sync.Transport {
+	t.Helper()
+
+	client, remote := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	go func() {
+		_ = server.Serve(remote)
+		_ = remote.Close()
+	}()
+
+	return sync.NewStreamTransport(client)
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		local   [][]byte
+		remote  [][]byte
+		params  []merkletree.Parameter
+		indices []uint64
+	}{
+		{
+			name:    "Same",
+			local:   syncData(100),
+			remote:  syncData(100),
+			indices: nil,
+		},
+		{
+			name:    "Changed",
+			local:   syncData(100),
+			remote:  syncData(100, 3, 64, 99),
+			indices: []uint64{3, 64, 99},
+		},
+		{
+			name:    "Longer",
+			local:   syncData(100),
+			remote:  syncData(110, 5),
+			indices: []uint64{5, 100, 101, 102, 103, 104, 105, 106, 107, 108, 109},
+		},
+		{
+			name:    "Shorter",
+			local:   syncData(100, 5),
+			remote:  syncData(90),
+			indices: []uint64{5},
+		},
+		{
+			name:    "DifferentShape",
+			local:   syncData(10),
+			remote:  syncData(20),
+			indices: []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+		},
+		{
+			name:    "PaddingDuplicateLast",
+			local:   syncData(3),
+			remote:  append(syncData(3), []byte("value 2")),
+			params:  []merkletree.Parameter{merkletree.WithPadding(merkletree.PaddingDuplicateLast)},
+			indices: []uint64{3},
+		},
+		{
+			name:    "Arity",
+			local:   syncData(100),
+			remote:  syncData(100, 50),
+			params:  []merkletree.Parameter{merkletree.WithArity(4)},
+			indices: []uint64{50},
+		},
+		{
+			name:    "Salted",
+			local:   syncData(100),
+			remote:  syncData(100, 50),
+			params:  []merkletree.Parameter{merkletree.WithSalt(true), merkletree.WithCountMixIn(true)},
+			indices: []uint64{50},
+		},
+	}
+
+	for _, test := range tests {
+		local, err := merkletree.NewTree(append([]merkletree.Parameter{merkletree.WithData(test.local)}, test.params...)...)
+		require.NoError(t, err, test.name)
+		remote, err := merkletree.NewTree(append([]merkletree.Parameter{merkletree.WithData(test.remote)}, test.params...)...)
+		require.NoError(t, err, test.name)
+		server, err := sync.NewServer(remote)
+		require.NoError(t, err, test.name)
+
+		for _, transport := range []sync.Transport{sync.NewMemoryTransport(server), streamTransport(t, server)} {
+			result, err := sync.Sync(local, transport)
+			require.NoError(t, err, test.name)
+			assert.Equal(t, test.indices, result.Indices, test.name)
+			assert.Equal(t, uint64(len(test.remote)), result.Count, test.name)
+
+			converged, err := result.Apply(local)
+			require.NoError(t, err, test.name)
+			assert.Equal(t, remote.Root(), converged.Root(), test.name)
+			assert.Equal(t, test.remote, converged.Data, test.name)
+		}
+	}
+}
+
+func TestSyncRequests(t *testing.T) {
+	local, err := merkletree.NewTree(merkletree.WithData(syncData(1024)))
+	require.NoError(t, err)
+	remote, err := merkletree.NewTree(merkletree.WithData(syncData(1024, 7)))
+	require.NoError(t, err)
+	server, err := sync.NewServer(remote)
+	require.NoError(t, err)
+
+	result, err := sync.Sync(local, sync.NewMemoryTransport(server))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7}, result.Indices)
+	// One request for info, one for each level of nodes and one for values.
+	assert.Equal(t, 1+11+1, result.Requests)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*sync.Request) (*sync.Response, error) {
+	return nil, errors.New("unavailable")
+}
+
+// infoTransport returns the given information about the remote tree.
+type infoTransport sync.Info
+
+func (t infoTransport) RoundTrip(*sync.Request) (*sync.Response, error) {
+	info := sync.Info(t)
+
+	return &sync.Response{Info: &info}, nil
+}
+
+func TestSyncErrors(t *testing.T) {
+	data := syncData(10)
+	tree, err := merkletree.NewTree(merkletree.WithData(data))
+	require.NoError(t, err)
+	server, err := sync.NewServer(tree)
+	require.NoError(t, err)
+	sortedTree, err := merkletree.NewTree(merkletree.WithData(data), merkletree.WithSorted(true))
+	require.NoError(t, err)
+	sortedServer, err := sync.NewServer(sortedTree)
+	require.NoError(t, err)
+	keccakTree, err := merkletree.NewTree(merkletree.WithData(data), merkletree.WithHashType(keccak256.New()))
+	require.NoError(t, err)
+	saltsTree, err := merkletree.NewTree(merkletree.WithData(data), merkletree.WithRandomSalts(true))
+	require.NoError(t, err)
+	leafHashTree, err := merkletree.NewTree(merkletree.WithLeafHashes(tree.Nodes[16:26]))
+	require.NoError(t, err)
+	leafHashServer, err := sync.NewServer(leafHashTree)
+	require.NoError(t, err)
+	longTree, err := merkletree.NewTree(merkletree.WithData(syncData(20)))
+	require.NoError(t, err)
+	longServer, err := sync.NewServer(longTree)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		local     *merkletree.MerkleTree
+		transport sync.Transport
+		err       string
+	}{
+		{
+			name:      "TreeMissing",
+			transport: sync.NewMemoryTransport(server),
+			err:       "no tree specified",
+		},
+		{
+			name:  "TransportMissing",
+			local: tree,
+			err:   "no transport specified",
+		},
+		{
+			name:      "LocalSorted",
+			local:     sortedTree,
+			transport: sync.NewMemoryTransport(server),
+			err:       "sorted trees are not supported",
+		},
+		{
+			name:      "RemoteSorted",
+			local:     tree,
+			transport: sync.NewMemoryTransport(sortedServer),
+			err:       "sorted trees are not supported",
+		},
+		{
+			name:      "Salts",
+			local:     saltsTree,
+			transport: sync.NewMemoryTransport(server),
+			err:       "trees with per-value salts are not supported",
+		},
+		{
+			name:      "LeafHashes",
+			local:     leafHashTree,
+			transport: sync.NewMemoryTransport(server),
+			err:       "trees created from leaf hashes are not supported",
+		},
+		{
+			name:      "HashType",
+			local:     keccakTree,
+			transport: sync.NewMemoryTransport(server),
+			err:       "replicas use different hash types",
+		},
+		{
+			name:      "RemoteNoData",
+			local:     longTree,
+			transport: sync.NewMemoryTransport(leafHashServer),
+			err:       "remote error: tree has no data",
+		},
+		{
+			name:      "TransportFails",
+			local:     tree,
+			transport: failingTransport{},
+			err:       "unavailable",
+		},
+		{
+			name:      "RemoteArity",
+			local:     tree,
+			transport: infoTransport{HashType: "blake2b", Arity: 3, Nodes: 32, Count: 10},
+			err:       "remote tree has an invalid arity",
+		},
+		{
+			name:      "RemoteNodes",
+			local:     tree,
+			transport: infoTransport{HashType: "blake2b", Arity: 2, Nodes: 31, Count: 10},
+			err:       "remote tree has an invalid number of nodes",
+		},
+		{
+			name:      "RemoteCountZero",
+			local:     tree,
+			transport: infoTransport{HashType: "blake2b", Arity: 2, Nodes: 32, Count: 0},
+			err:       "remote tree has an invalid count",
+		},
+		{
+			name:      "RemoteCountLarge",
+			local:     tree,
+			transport: infoTransport{HashType: "blake2b", Arity: 2, Nodes: 32, Count: 1 << 40},
+			err:       "remote tree has an invalid count",
+		},
+		{
+			name:      "RemoteRoot",
+			local:     tree,
+			transport: infoTransport{HashType: "blake2b", Arity: 2, Nodes: 32, Count: 10, Root: []byte{0x01}},
+			err:       "remote tree has an invalid root",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := sync.Sync(test.local, test.transport)
+		assert.EqualError(t, err, test.err, test.name)
+	}
+
+	_, err = (&sync.Result{}).Apply(nil)
+	assert.EqualError(t, err, "no tree specified")
+	_, err = (&sync.Result{Count: 1, Indices: []uint64{0}}).Apply(tree)
+	assert.EqualError(t, err, "indices and values must be the same length")
+	_, err = (&sync.Result{}).Apply(tree)
+	assert.EqualError(t, err, "remote tree has no values")
+	_, err = (&sync.Result{Count: 1 << 40}).Apply(tree)
+	assert.EqualError(t, err, "count exceeds the local and remote values")
+	_, err = (&sync.Result{Count: 1, Indices: []uint64{1}, Values: [][]byte{{0x01}}}).Apply(tree)
+	assert.EqualError(t, err, "index out of range")
+	_, err = (&sync.Result{Count: 12, Indices: []uint64{10, 10}, Values: [][]byte{{0x01}, {0x02}}}).Apply(tree)
+	assert.EqualError(t, err, "missing value at index 11")
+	_, err = (&sync.Result{Count: 12, Indices: []uint64{0, 1}, Values: [][]byte{{0x01}, {0x02}}}).Apply(tree)
+	assert.EqualError(t, err, "missing value at index 10")
+
+	// A remote replica that returns incorrect values is detected.
+	result, err := sync.Sync(tree, sync.NewMemoryTransport(longServer))
+	require.NoError(t, err)
+	result.Values[len(result.Values)-1] = []byte("incorrect")
+	_, err = result.Apply(tree)
+	assert.EqualError(t, err, "root does not match remote root")
+}
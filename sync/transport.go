@@ -0,0 +1,85 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Transport sends requests to a remote replica.
+type Transport interface {
+	// RoundTrip sends the request and returns the response from the remote replica.
+	RoundTrip(request *Request) (*Response, error)
+}
+
+// memoryTransport is a transport to a server in the same process.
+type memoryTransport struct {
+	server *Server
+}
+
+// NewMemoryTransport creates a transport that sends requests directly to the server.
+func NewMemoryTransport(server *Server) Transport {
+	return &memoryTransport{
+		server: server,
+	}
+}
+
+// RoundTrip implements Transport.
+func (t *memoryTransport) RoundTrip(request *Request) (*Response, error) {
+	return t.server.Handle(request), nil
+}
+
+// streamTransport is a transport over a stream.
+type streamTransport struct {
+	writer  io.Writer
+	decoder *json.Decoder
+}
+
+// NewStreamTransport creates a transport that sends JSON-encoded requests over the stream, for example a net.Conn, and reads
+// JSON-encoded responses from it.  The remote end of the stream should be handled by Server.Serve().
+// The transport is not safe for concurrent use.
+func NewStreamTransport(rw io.ReadWriter) Transport {
+	return &streamTransport{
+		writer:  rw,
+		decoder: json.NewDecoder(rw),
+	}
+}
+
+// RoundTrip implements Transport.
+func (t *streamTransport) RoundTrip(request *Request) (*Response, error) {
+	if err := writeMessage(t.writer, request); err != nil {
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	}
+	var response Response
+	if err := t.decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// writeMessage writes the JSON encoding of the message to the writer.
+// The message is written in a single call without a trailing delimiter, so that the write completes as soon as the remote end
+// has decoded the message even if the stream is unbuffered, as is the case with net.Pipe().
+func writeMessage(writer io.Writer, message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+
+	return err
+}
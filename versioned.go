@@ -0,0 +1,422 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// VersionedTree is a persistent Merkle tree in which each change creates a new version.  Versions share the subtrees that are
+// unchanged between them, so retaining a version costs only the nodes on the paths of the leaves changed after it.
+//
+// The root of each version is the same as that of a MerkleTree created from the values of that version with the same
+// parameters, and proofs generated against a version are verified in the same way.  Versioned trees cannot be sorted, have
+// per-value salts or use PaddingDuplicateLast, as each of these requires changes to the tree beyond the changed leaves.
+//
+// A versioned tree is safe for concurrent use.
+type VersionedTree struct {
+	hash       HashType
+	saltFunc   SaltFunc
+	padding    Padding
+	countMixIn bool
+	arity      int
+
+	// empties are the empty subtrees of each height.
+	empties []*versionNode
+
+	mu sync.RWMutex
+	// versions are the retained versions, oldest first.
+	versions []*TreeVersion
+}
+
+// TreeVersion is an immutable version of a VersionedTree.
+type TreeVersion struct {
+	tree    *VersionedTree
+	version uint64
+	node    *versionNode
+	depth   int
+	count   uint64
+	root    []byte
+}
+
+// versionNode is a node of a versioned tree.  Branches have children; leaves do not.
+// A nil node is an empty subtree in an unbalanced tree.
+type versionNode struct {
+	hash     []byte
+	children []*versionNode
+}
+
+// NewVersionedTree creates a new versioned tree, with the data as its first version.
+func NewVersionedTree(params ...Parameter) (*VersionedTree, error) {
+	parameters, err := parseAndCheckTreeParameters(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem with parameters")
+	}
+	if parameters.leafHashes != nil || parameters.dataFunc != nil {
+		return nil, errors.New("versioned trees must be created from data")
+	}
+	if parameters.sorted {
+		return nil, errors.New("versioned trees cannot be sorted")
+	}
+	if parameters.salts != nil {
+		return nil, errors.New("versioned trees cannot have per-value salts")
+	}
+	if parameters.padding == PaddingDuplicateLast {
+		return nil, errors.New("versioned trees cannot use duplicate-last padding")
+	}
+
+	t := &VersionedTree{
+		hash:       parameters.hash,
+		saltFunc:   parameters.saltFunc,
+		padding:    parameters.padding,
+		countMixIn: parameters.countMixIn,
+		arity:      parameters.arity,
+	}
+
+	depth := depthFor(uint64(len(parameters.data)), t.k())
+	if width := depthFor(parameters.width, t.k()); width > depth {
+		depth = width
+	}
+	hasher := newHasher(t.hash)
+	t.grow(hasher, depth)
+	node := t.write(hasher, t.empties[depth], depth, 0, 0, t.leaves(hasher, 0, parameters.data))
+	t.versions = []*TreeVersion{t.newVersion(hasher, 0, node, depth, uint64(len(parameters.data)))}
+
+	return t, nil
+}
+
+// Latest returns the latest version of the tree.
+func (t *VersionedTree) Latest() *TreeVersion {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.versions[len(t.versions)-1]
+}
+
+// At returns the given version of the tree.
+// If the version does not exist, or has been pruned, this will return an error.
+func (t *VersionedTree) At(version uint64) (*TreeVersion, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	first := t.versions[0].version
+	if version < first || version-first >= uint64(len(t.versions)) {
+		return nil, fmt.Errorf("version %d not available", version)
+	}
+
+	return t.versions[version-first], nil
+}
+
+// Versions returns the numbers of the retained versions of the tree, oldest first.
+func (t *VersionedTree) Versions() []uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	versions := make([]uint64, len(t.versions))
+	for i := range t.versions {
+		versions[i] = t.versions[i].version
+	}
+
+	return versions
+}
+
+// GenerateProof generates the proof for the data at the given index of the given version of the tree, to its root.
+func (t *VersionedTree) GenerateProof(version uint64, index uint64) (*Proof, error) {
+	v, err := t.At(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.GenerateProofWithIndex(index, 0)
+}
+
+// Replace replaces the data at the given index, returning the new version of the tree.
+func (t *VersionedTree) Replace(index uint64, data []byte) (*TreeVersion, error) {
+	if err := checkLeaf(t.hash, data, saltFor(t.saltFunc, index)); err != nil {
+		return nil, errors.Wrap(err, "invalid data")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latest := t.versions[len(t.versions)-1]
+	if index >= latest.count {
+		return nil, errors.New("index out of range")
+	}
+
+	hasher := newHasher(t.hash)
+	node := t.write(hasher, latest.node, latest.depth, 0, index, t.leaves(hasher, index, [][]byte{data}))
+
+	return t.addVersion(hasher, node, latest.depth, latest.count), nil
+}
+
+// Append appends the data to the tree, returning the new version of the tree.
+func (t *VersionedTree) Append(data ...[]byte) (*TreeVersion, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no data specified")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latest := t.versions[len(t.versions)-1]
+	for i := range data {
+		if err := checkLeaf(t.hash, data[i], saltFor(t.saltFunc, latest.count+uint64(i))); err != nil {
+			return nil, fmt.Errorf("invalid data at index %d: %w", i, err)
+		}
+	}
+	count := latest.count + uint64(len(data))
+	hasher := newHasher(t.hash)
+
+	// Add levels above the root until the tree is wide enough, with the existing tree as the first child of each new root.
+	node := latest.node
+	depth := latest.depth
+	for ; power(t.k(), depth) < count; depth++ {
+		t.grow(hasher, depth+1)
+		children := make([]*versionNode, t.k())
+		children[0] = node
+		for i := 1; i < len(children); i++ {
+			children[i] = t.empties[depth]
+		}
+		node = t.branch(hasher, children)
+	}
+	node = t.write(hasher, node, depth, 0, latest.count, t.leaves(hasher, latest.count, data))
+
+	return t.addVersion(hasher, node, depth, count), nil
+}
+
+// Prune removes all versions before the given version.  The latest version is always retained.
+func (t *VersionedTree) Prune(before uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := 0
+	for i < len(t.versions)-1 && t.versions[i].version < before {
+		// Release the version so that nodes it does not share with retained versions can be collected.
+		t.versions[i] = nil
+		i++
+	}
+	t.versions = t.versions[i:]
+}
+
+// Version returns the number of the version.
+func (v *TreeVersion) Version() uint64 {
+	return v.version
+}
+
+// Count returns the number of values in the version.
+func (v *TreeVersion) Count() uint64 {
+	return v.count
+}
+
+// Root returns the Merkle root of the version.
+// If the tree mixes its count in to the root this is the root after the count has been mixed in.
+func (v *TreeVersion) Root() []byte {
+	return v.root
+}
+
+// GenerateProofWithIndex generates the proof for the data at the given index of the version.
+// Height is the height of the pollard to verify the proof.  If using the Merkle root to verify this should be 0.
+// If the tree mixes its count in to the root and the height is 0 the proof also contains the count.
+func (v *TreeVersion) GenerateProofWithIndex(index uint64, height int) (*Proof, error) {
+	if index >= v.count {
+		return nil, errors.New("index out of range")
+	}
+	if height < 0 || height > v.depth {
+		return nil, errors.New("height out of range")
+	}
+
+	arity := v.tree.k()
+	levels := make([][][]byte, 0, v.depth-height)
+	node := v.node
+	for level := v.depth; level > 0; level-- {
+		position := (index / power(arity, level-1)) % arity
+		siblings := make([][]byte, 0, arity-1)
+		for j := uint64(0); j < arity; j++ {
+			if j != position {
+				siblings = append(siblings, nodeHash(childOf(node, j)))
+			}
+		}
+		if level <= v.depth-height {
+			levels = append(levels, siblings)
+		}
+		node = childOf(node, position)
+	}
+
+	// Proofs run from the leaf to the root.
+	hashes := make([][]byte, 0, len(levels)*int(arity-1))
+	for i := len(levels) - 1; i >= 0; i-- {
+		hashes = append(hashes, levels[i]...)
+	}
+
+	proof := newProof(hashes, index)
+	proof.Arity = v.tree.arity
+	if v.tree.countMixIn && height == 0 {
+		proof.Count = v.count
+	}
+
+	return proof, nil
+}
+
+// k returns the arity of the tree.
+func (t *VersionedTree) k() uint64 {
+	return arityOf(t.arity)
+}
+
+// grow ensures that the empty subtrees are available up to the given height.
+func (t *VersionedTree) grow(hasher *hasher, height int) {
+	if len(t.empties) == 0 {
+		if t.padding == PaddingNone {
+			t.empties = append(t.empties, nil)
+		} else {
+			t.empties = append(t.empties, &versionNode{hash: paddingLeaf(t.padding, t.hash, nil)})
+		}
+	}
+	for len(t.empties) <= height {
+		below := t.empties[len(t.empties)-1]
+		if below == nil {
+			t.empties = append(t.empties, nil)
+
+			continue
+		}
+		children := make([]*versionNode, t.k())
+		for i := range children {
+			children[i] = below
+		}
+		t.empties = append(t.empties, t.branch(hasher, children))
+	}
+}
+
+// leaves hashes the data to form leaves, starting at the given index.
+func (t *VersionedTree) leaves(hasher *hasher, index uint64, data [][]byte) [][]byte {
+	leaves := make([][]byte, len(data))
+	for i := range data {
+		leaves[i] = hasher.hashLeaf(nil, data[i], saltFor(t.saltFunc, index+uint64(i)))
+	}
+
+	return leaves
+}
+
+// write writes consecutive leaves starting at the index first in to the subtree of the given height whose first leaf is at the
+// index base, returning the new subtree.  Children that do not contain any of the leaves are shared with the existing subtree.
+func (t *VersionedTree) write(hasher *hasher, node *versionNode, height int, base uint64, first uint64, leaves [][]byte) *versionNode {
+	if height == 0 {
+		return &versionNode{hash: leaves[0]}
+	}
+
+	arity := t.k()
+	children := make([]*versionNode, arity)
+	for i := range children {
+		children[i] = childOf(node, uint64(i))
+		if children[i] == nil {
+			children[i] = t.empties[height-1]
+		}
+	}
+
+	span := power(arity, height-1)
+	last := first + uint64(len(leaves))
+	for i := uint64(0); i < arity; i++ {
+		start := base + i*span
+		end := start + span
+		if end <= first || start >= last {
+			continue
+		}
+		from := first
+		if start > from {
+			from = start
+		}
+		to := last
+		if end < to {
+			to = end
+		}
+		children[i] = t.write(hasher, children[i], height-1, start, from, leaves[from-first:to-first])
+	}
+
+	return t.branch(hasher, children)
+}
+
+// branch creates a branch node from its children.
+// As per PaddingNone, a branch without a right child takes the hash of its left child, and a branch without children is empty.
+func (t *VersionedTree) branch(hasher *hasher, children []*versionNode) *versionNode {
+	if children[len(children)-1] == nil {
+		if children[0] == nil {
+			return nil
+		}
+
+		return &versionNode{hash: children[0].hash, children: children}
+	}
+
+	hashes := make([][]byte, len(children))
+	for i := range children {
+		hashes[i] = children[i].hash
+	}
+
+	return &versionNode{hash: hasher.hashChildren(nil, hashes), children: children}
+}
+
+// addVersion adds a new latest version of the tree.
+func (t *VersionedTree) addVersion(hasher *hasher, node *versionNode, depth int, count uint64) *TreeVersion {
+	version := t.newVersion(hasher, t.versions[len(t.versions)-1].version+1, node, depth, count)
+	t.versions = append(t.versions, version)
+
+	return version
+}
+
+// newVersion creates a version of the tree.
+func (t *VersionedTree) newVersion(hasher *hasher, version uint64, node *versionNode, depth int, count uint64) *TreeVersion {
+	root := node.hash
+	if t.countMixIn {
+		root = mixInCount(hasher, root, count)
+	}
+
+	return &TreeVersion{
+		tree:    t,
+		version: version,
+		node:    node,
+		depth:   depth,
+		count:   count,
+		root:    root,
+	}
+}
+
+// childOf returns the child of the node at the given position, or nil if the node is empty.
+func childOf(node *versionNode, position uint64) *versionNode {
+	if node == nil {
+		return nil
+	}
+
+	return node.children[position]
+}
+
+// nodeHash returns the hash of the node, or nil if the node is empty.
+func nodeHash(node *versionNode) []byte {
+	if node == nil {
+		return nil
+	}
+
+	return node.hash
+}
+
+// power returns base raised to the given exponent.
+func power(base uint64, exponent int) uint64 {
+	res := uint64(1)
+	for i := 0; i < exponent; i++ {
+		res *= base
+	}
+
+	return res
+}
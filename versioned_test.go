@@ -0,0 +1,161 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestVersionedTree(t *testing.T) {
+	tests := []struct {
+		params []Parameter
+	}{
+		{},
+		{
+			params: []Parameter{WithHashType(keccak256.New()), WithSalt(true)},
+		},
+		{
+			params: []Parameter{WithPadding(PaddingEmptyHash), WithCountMixIn(true)},
+		},
+		{
+			params: []Parameter{WithPadding(PaddingNone)},
+		},
+		{
+			params: []Parameter{WithArity(4), WithSaltFunc(IndexSalt64)},
+		},
+	}
+
+	for i, test := range tests {
+		data := diffData(3)
+		tree, err := NewVersionedTree(append([]Parameter{WithData(data)}, test.params...)...)
+		require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+
+		// Build up a series of versions, recording the data of each.
+		history := [][][]byte{data}
+		for j := 0; j < 6; j++ {
+			data = append(append([][]byte{}, data...), diffData(j + 3)[j+1:]...)
+			_, err := tree.Append(diffData(j + 3)[j+1:]...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			history = append(history, data)
+
+			data = append([][]byte{}, data...)
+			data[j] = []byte(fmt.Sprintf("replaced value %d", j))
+			_, err = tree.Replace(uint64(j), data[j])
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			history = append(history, data)
+		}
+		assert.Equal(t, uint64(len(history)-1), tree.Latest().Version(), fmt.Sprintf("failed at test %d", i))
+
+		for version, values := range history {
+			expected, err := NewTree(append([]Parameter{WithData(values)}, test.params...)...)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			v, err := tree.At(uint64(version))
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, uint64(len(values)), v.Count(), fmt.Sprintf("failed at test %d version %d", i, version))
+			assert.Equal(t, expected.Root(), v.Root(), fmt.Sprintf("failed at test %d version %d", i, version))
+
+			for index := range values {
+				proof, err := tree.GenerateProof(uint64(version), uint64(index))
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				expectedProof, err := expected.GenerateProofWithIndex(uint64(index), 0)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.Equal(t, expectedProof, proof, fmt.Sprintf("failed at test %d version %d index %d", i, version, index))
+				verified, err := VerifyProofUsingSaltFunc(values[index], expected.saltFunc(), proof, [][]byte{v.Root()}, expected.Hash)
+				require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+				assert.True(t, verified, fmt.Sprintf("failed at test %d version %d index %d", i, version, index))
+			}
+
+			proof, err := v.GenerateProofWithIndex(0, 1)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			expectedProof, err := expected.GenerateProofWithIndex(0, 1)
+			require.NoError(t, err, fmt.Sprintf("failed at test %d", i))
+			assert.Equal(t, expectedProof, proof, fmt.Sprintf("failed at test %d version %d", i, version))
+		}
+	}
+}
+
+func TestVersionedTreeSharing(t *testing.T) {
+	tree, err := NewVersionedTree(WithData(diffData(16)))
+	require.NoError(t, err)
+	first := tree.Latest()
+	second, err := tree.Replace(3, []byte("replaced"))
+	require.NoError(t, err)
+
+	// Only the path to the replaced leaf is new.
+	assert.NotSame(t, first.node, second.node)
+	assert.Same(t, first.node.children[1], second.node.children[1])
+	assert.NotSame(t, first.node.children[0], second.node.children[0])
+	assert.Same(t, first.node.children[0].children[1], second.node.children[0].children[1])
+}
+
+func TestVersionedTreePrune(t *testing.T) {
+	tree, err := NewVersionedTree(WithData(diffData(4)))
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err := tree.Append([]byte(fmt.Sprintf("appended %d", i)))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4}, tree.Versions())
+
+	tree.Prune(2)
+	assert.Equal(t, []uint64{2, 3, 4}, tree.Versions())
+	_, err = tree.At(1)
+	assert.EqualError(t, err, "version 1 not available")
+	_, err = tree.GenerateProof(0, 0)
+	assert.EqualError(t, err, "version 0 not available")
+	v, err := tree.At(2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), v.Count())
+
+	// The latest version is always retained.
+	tree.Prune(10)
+	assert.Equal(t, []uint64{4}, tree.Versions())
+	_, err = tree.At(5)
+	assert.EqualError(t, err, "version 5 not available")
+
+	// New versions continue to be numbered after pruning.
+	v, err = tree.Replace(0, []byte("replaced"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), v.Version())
+}
+
+func TestVersionedTreeErrors(t *testing.T) {
+	_, err := NewVersionedTree()
+	assert.EqualError(t, err, "problem with parameters: tree must have at least 1 piece of data")
+	_, err = NewVersionedTree(WithLeafHashes([][]byte{make([]byte, 32)}))
+	assert.EqualError(t, err, "versioned trees must be created from data")
+	_, err = NewVersionedTree(WithData(diffData(2)), WithSorted(true))
+	assert.EqualError(t, err, "versioned trees cannot be sorted")
+	_, err = NewVersionedTree(WithData(diffData(2)), WithSalts([][]byte{bytes.Repeat([]byte{0x01}, 32), bytes.Repeat([]byte{0x02}, 32)}))
+	assert.EqualError(t, err, "versioned trees cannot have per-value salts")
+	_, err = NewVersionedTree(WithData(diffData(2)), WithPadding(PaddingDuplicateLast))
+	assert.EqualError(t, err, "versioned trees cannot use duplicate-last padding")
+
+	tree, err := NewVersionedTree(WithData(diffData(2)))
+	require.NoError(t, err)
+	_, err = tree.Replace(2, []byte("replaced"))
+	assert.EqualError(t, err, "index out of range")
+	_, err = tree.Append()
+	assert.EqualError(t, err, "no data specified")
+	_, err = tree.Latest().GenerateProofWithIndex(2, 0)
+	assert.EqualError(t, err, "index out of range")
+	_, err = tree.Latest().GenerateProofWithIndex(0, 2)
+	assert.EqualError(t, err, "height out of range")
+}
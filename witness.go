@@ -0,0 +1,95 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// CircuitWitness is the input to a zero-knowledge circuit that proves the inclusion of a value in a Merkle tree.
+// Field elements are provided as decimal strings, as used in the input files of circom and gnark.
+type CircuitWitness struct {
+	// Leaf is the value being proved, as a field element.
+	Leaf string `json:"leaf"`
+	// Depth is the number of levels of the proof before padding.
+	Depth int `json:"depth"`
+	// Indices are the path bits from the leaf to the root; 0 if the node at that level is on the left, otherwise 1.
+	Indices []int `json:"indices"`
+	// Siblings are the sibling hashes from the leaf to the root, as field elements.
+	Siblings []string `json:"siblings"`
+	// Root is the root of the tree, as a field element.
+	Root string `json:"root"`
+}
+
+// CircuitWitness creates the input to a zero-knowledge circuit that proves the inclusion of data in the Merkle tree, using the
+// provided hash type which must operate on field elements.  The proof must be to the root of the tree, i.e. generated with a
+// height of 0.
+//
+// Indices and siblings are padded to the fixed depth of the circuit with 0, and the number of levels actually used is returned
+// in the witness's Depth; this is the layout expected by variable-depth circuits such as zk-kit's BinaryMerkleRoot.  If the tree
+// is salted the salt is not included in the witness, as it is the index of the leaf and so can be recreated by the circuit from
+// the indices.
+func (p *Proof) CircuitWitness(data []byte, salt bool, depth int, hashType HashType) (*CircuitWitness, error) {
+	fieldHashType, isFieldHashType := hashType.(FieldElementHashType)
+	if !isFieldHashType {
+		return nil, errors.New("hash type does not operate on field elements")
+	}
+	if p.Count != 0 {
+		return nil, errors.New("proofs with a mixed-in count are not supported")
+	}
+	if arityOf(p.Arity) != 2 {
+		return nil, errors.New("proofs of trees with an arity other than 2 are not supported")
+	}
+	if depth < len(p.Hashes) {
+		return nil, fmt.Errorf("proof requires depth of at least %d", len(p.Hashes))
+	}
+
+	leaf, err := fieldHashType.FieldElement(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid leaf")
+	}
+
+	witness := &CircuitWitness{
+		Leaf:     leaf.String(),
+		Depth:    len(p.Hashes),
+		Indices:  make([]int, depth),
+		Siblings: make([]string, depth),
+	}
+	for i := 0; i < depth; i++ {
+		if i >= len(p.Hashes) {
+			witness.Siblings[i] = "0"
+
+			continue
+		}
+		if p.Hashes[i] == nil {
+			return nil, errors.New("proofs of unbalanced trees are not supported")
+		}
+		sibling, err := fieldHashType.FieldElement(p.Hashes[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sibling at level %d", i)
+		}
+		witness.Siblings[i] = sibling.String()
+		witness.Indices[i] = int((p.Index >> uint(i)) & 1)
+	}
+
+	root, err := fieldHashType.FieldElement(generateProofHash(data, saltFor(saltFuncFor(salt), p.Index), p, false, hashType))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid root")
+	}
+	witness.Root = root.String()
+
+	return witness, nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2024 Weald Technology Trading.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wealdtech/go-merkletree/v2/blake2b"
+	"github.com/wealdtech/go-merkletree/v2/mimc"
+	"github.com/wealdtech/go-merkletree/v2/poseidon"
+)
+
+func TestCircuitWitness(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}}
+
+	tree, err := NewTree(
+		WithData(data),
+		WithHashType(poseidon.NewFieldElements()),
+	)
+	require.NoError(t, err)
+
+	proof, err := tree.GenerateProofWithIndex(2, 0)
+	require.NoError(t, err)
+
+	hashType := poseidon.NewFieldElements()
+	sibling := new(big.Int).SetBytes(hashType.Hash(hashType.Hash(data[0]), hashType.Hash(data[1])))
+	require.Equal(t, "10058687713083746196667355667918512760470030038024584531967182749893253193558", sibling.String())
+
+	witness, err := proof.CircuitWitness(data[2], false, 4, tree.Hash)
+	require.NoError(t, err)
+	exported, err := json.Marshal(witness)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).SetBytes(tree.Root()).String(), witness.Root)
+	require.Equal(t, `{"leaf":"3","depth":2,"indices":[0,1,0,0],"siblings":["0","10058687713083746196667355667918512760470030038024584531967182749893253193558","0","0"],"root":"3616733399795105187266088963403421556811985165643930621622020739828976703493"}`, string(exported))
+}
+
+func TestCircuitWitnessSalted(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}, {0x04}, {0x05}}
+
+	tree, err := NewTree(
+		WithData(data),
+		WithHashType(mimc.New()),
+		WithSalt(true),
+	)
+	require.NoError(t, err)
+
+	for i := range data {
+		proof, err := tree.GenerateProofWithIndex(uint64(i), 0)
+		require.NoError(t, err)
+		witness, err := proof.CircuitWitness(data[i], true, 3, tree.Hash)
+		require.NoError(t, err)
+		require.Equal(t, new(big.Int).SetBytes(tree.Root()).String(), witness.Root)
+		require.Equal(t, []int{i & 1, (i >> 1) & 1, (i >> 2) & 1}, witness.Indices)
+	}
+}
+
+func TestCircuitWitnessErrors(t *testing.T) {
+	data := [][]byte{{0x01}, {0x02}, {0x03}}
+
+	tree, err := NewTree(
+		WithData(data),
+		WithHashType(poseidon.NewFieldElements()),
+	)
+	require.NoError(t, err)
+	proof, err := tree.GenerateProofWithIndex(0, 0)
+	require.NoError(t, err)
+
+	_, err = proof.CircuitWitness(data[0], false, 1, tree.Hash)
+	require.EqualError(t, err, "proof requires depth of at least 2")
+
+	_, err = proof.CircuitWitness(data[0], false, 2, blake2b.New())
+	require.EqualError(t, err, "hash type does not operate on field elements")
+
+	_, err = proof.CircuitWitness(data[0], false, 2, poseidon.New())
+	require.EqualError(t, err, "invalid leaf: not in field element mode")
+
+	unbalancedTree, err := NewTree(
+		WithData(data),
+		WithHashType(poseidon.NewFieldElements()),
+		WithPadding(PaddingNone),
+	)
+	require.NoError(t, err)
+	proof, err = unbalancedTree.GenerateProofWithIndex(2, 0)
+	require.NoError(t, err)
+	_, err = proof.CircuitWitness(data[2], false, 2, unbalancedTree.Hash)
+	require.EqualError(t, err, "proofs of unbalanced trees are not supported")
+}